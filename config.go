@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/czerwonk/ovirt_exporter/pkg/config"
+)
+
+// flagSettings maps flags to the setting in the configuration they define
+var flagSettings = map[string]func(c *config.Config){
+	"api.url":                          func(c *config.Config) { c.API.URL = *apiURL },
+	"api.username":                     func(c *config.Config) { c.API.Username = *apiUser },
+	"api.password":                     func(c *config.Config) { c.API.Password = *apiPass },
+	"api.password-file":                func(c *config.Config) { c.API.PasswordFile = *apiPassFile },
+	"api.password-file-check-interval": func(c *config.Config) { c.API.PasswordFileCheckInterval = *apiPassFileInterval },
+	"api.auth-method":                  func(c *config.Config) { c.API.AuthMethod = *apiAuthMethod },
+	"api.insecure-cert":                func(c *config.Config) { c.API.InsecureCert = *apiInsecureCert },
+	"api.ca-file":                      func(c *config.Config) { c.API.CAFile = *apiCAFile },
+	"api.ca-fetch":                     func(c *config.Config) { c.API.CAFetch = *apiCAFetch },
+	"api.ca-fingerprint":               func(c *config.Config) { c.API.CAFingerprint = *apiCAFingerprint },
+	"api.client-cert-file":             func(c *config.Config) { c.API.ClientCertFile = *apiClientCertFile },
+	"api.client-key-file":              func(c *config.Config) { c.API.ClientKeyFile = *apiClientKeyFile },
+	"api.tls-min-version":              func(c *config.Config) { c.API.TLSMinVersion = *apiTLSMinVersion },
+	"api.proxy-url":                    func(c *config.Config) { c.API.ProxyURL = *apiProxyURL },
+	"api.timeout":                      func(c *config.Config) { c.API.Timeout = *apiTimeout },
+	"api.max-idle-connections":         func(c *config.Config) { c.API.MaxIdleConnections = *apiMaxIdleConns },
+	"api.idle-connection-timeout":      func(c *config.Config) { c.API.IdleConnectionTimeout = *apiIdleConnTimeout },
+	"api.max-concurrent-requests":      func(c *config.Config) { c.API.MaxConcurrentRequests = *apiMaxConcurrent },
+	"api.rate-limit":                   func(c *config.Config) { c.API.RateLimit = *apiRateLimit },
+	"api.rate-limit-burst":             func(c *config.Config) { c.API.RateLimitBurst = *apiRateLimitBurst },
+	"api.rate-limit-latency-threshold": func(c *config.Config) { c.API.RateLimitLatencyThreshold = *apiRateLimitLatency },
+	"api.retries":                      func(c *config.Config) { c.API.Retries = *apiRetries },
+	"api.retry-backoff":                func(c *config.Config) { c.API.RetryBackoff = *apiRetryBackoff },
+	"api.retry-max-backoff":            func(c *config.Config) { c.API.RetryMaxBackoff = *apiRetryMaxBackoff },
+	"api.circuit-breaker-threshold":    func(c *config.Config) { c.API.CircuitBreakerThreshold = *apiBreakerThreshold },
+	"api.circuit-breaker-cooldown":     func(c *config.Config) { c.API.CircuitBreakerCooldown = *apiBreakerCooldown },
+	"api.prefetch":                     func(c *config.Config) { c.API.Prefetch = *prefetch },
+	"api.follow":                       func(c *config.Config) { c.API.Follow = *follow },
+	"with-snapshots":                   func(c *config.Config) { c.Collectors.Snapshots = *withSnapshots },
+	"with-network":                     func(c *config.Config) { c.Collectors.Network = *withNetwork },
+	"with-disks":                       func(c *config.Config) { c.Collectors.Disks = *withDisks },
+	"metrics.allow":                    func(c *config.Config) { c.Metrics.Allow = splitList(*metricsAllow) },
+	"metrics.deny":                     func(c *config.Config) { c.Metrics.Deny = splitList(*metricsDeny) },
+	"tracing.enabled":                  func(c *config.Config) { c.Tracing.Enabled = *tracingEnabled },
+	"tracing.provider":                 func(c *config.Config) { c.Tracing.Provider = *tracingProvider },
+	"tracing.collector.grpc-endpoint":  func(c *config.Config) { c.Tracing.CollectorEndpoint = *tracingCollectorEndpoint },
+}
+
+// loadConfig builds the configuration from the defaults of the flags, the config file and the flags set explicitly (in this order)
+func loadConfig() (*config.Config, error) {
+	cfg := &config.Config{}
+	for _, set := range flagSettings {
+		set(cfg)
+	}
+
+	if *configFile != "" {
+		err := config.Load(*configFile, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	flag.Visit(func(f *flag.Flag) {
+		if set, found := flagSettings[f.Name]; found {
+			set(cfg)
+		}
+	})
+
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// splitList splits a comma separated list of values given by a flag (nil if empty)
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(s, ",")
+}
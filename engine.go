@@ -0,0 +1,583 @@
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/czerwonk/ovirt_exporter/pkg/api"
+	"github.com/czerwonk/ovirt_exporter/pkg/coalesce"
+	"github.com/czerwonk/ovirt_exporter/pkg/collector"
+	"github.com/czerwonk/ovirt_exporter/pkg/config"
+	"github.com/czerwonk/ovirt_exporter/pkg/credentials"
+	"github.com/czerwonk/ovirt_exporter/pkg/filter"
+	"github.com/czerwonk/ovirt_exporter/pkg/host"
+	"github.com/czerwonk/ovirt_exporter/pkg/metric"
+	"github.com/czerwonk/ovirt_exporter/pkg/namecache"
+	"github.com/czerwonk/ovirt_exporter/pkg/poller"
+	"github.com/czerwonk/ovirt_exporter/pkg/storagedomain"
+	"github.com/czerwonk/ovirt_exporter/pkg/vm"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// connectRetryInterval is the interval in which the connection to an unavailable engine is retried
+const connectRetryInterval = 30 * time.Second
+
+var (
+	errNotConnected = errors.New("not connected to the API")
+	connectedDesc   = prometheus.NewDesc("ovirt_exporter_api_connected", "Connection to the API is established (1) or not (0)", nil, nil)
+)
+
+// engine bundles the connection to an oVirt engine and the components collecting its metrics
+type engine struct {
+	settings     config.Settings
+	creds        *credentials.Source
+	client       atomic.Pointer[api.Client]
+	connectMutex sync.Mutex
+	connected    chan struct{}
+	limiter      *collector.Limiter
+	rateLimiter  *collector.RateLimiter
+	breaker      *collector.CircuitBreaker
+	cc           *collector.CollectorContext
+	poller       *poller.Poller
+	scrapes      *coalesce.Group
+	coalesced    prometheus.Counter
+	durations    *prometheus.HistogramVec
+	tokens       tokenCounters
+	names        *namecache.Set
+	filters      engineFilters
+	ctx          context.Context
+	cancel       context.CancelFunc
+}
+
+// engineFilters are the compiled filters of the VMs, hosts and storage domains collected
+type engineFilters struct {
+	vms            *filter.Filter
+	hosts          *filter.Filter
+	storageDomains *filter.Filter
+}
+
+func newEngineFilters(f config.Filters) (engineFilters, error) {
+	var res engineFilters
+	var err error
+
+	res.vms, err = filter.New(f.VMs)
+	if err != nil {
+		return res, err
+	}
+
+	res.hosts, err = filter.New(f.Hosts)
+	if err != nil {
+		return res, err
+	}
+
+	res.storageDomains, err = filter.New(f.StorageDomains)
+	return res, err
+}
+
+// newEngine creates the components for the engine and watches the credentials for changes.
+// The connection to the API is established by connect.
+func newEngine(ctx context.Context, s config.Settings) (*engine, error) {
+	a := s.API
+
+	creds, err := credentials.NewSource(a.PasswordFile, a.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	filters, err := newEngineFilters(s.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	metricFilter, err := metric.NewFilter(s.Metrics.Allow, s.Metrics.Deny)
+	if err != nil {
+		return nil, err
+	}
+
+	coalesced := newCoalescedRequestsCounter()
+
+	ctx, cancel := context.WithCancel(ctx)
+	e := &engine{
+		settings:    s,
+		creds:       creds,
+		limiter:     collector.NewLimiter(a.MaxConcurrentRequests),
+		rateLimiter: collector.NewRateLimiter(a.RateLimit, a.RateLimitBurst, a.RateLimitLatencyThreshold),
+		breaker:     collector.NewCircuitBreaker(a.CircuitBreakerThreshold, a.CircuitBreakerCooldown),
+		scrapes:     coalesce.NewGroup(coalesced),
+		coalesced:   coalesced,
+		durations:   newCollectorDurationHistogram(),
+		tokens:      newTokenCounters(),
+		names:       namecache.NewSet(),
+		connected:   make(chan struct{}),
+		filters:     filters,
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+	e.cc = collector.NewContext(tracer, e,
+		collector.WithLimiter(e.limiter),
+		collector.WithRateLimiter(e.rateLimiter),
+		collector.WithCircuitBreaker(e.breaker),
+		collector.WithMetricFilter(metricFilter),
+		collector.WithRetryPolicy(collector.RetryPolicy{
+			MaxRetries: a.Retries,
+			Backoff:    a.RetryBackoff,
+			MaxBackoff: a.RetryMaxBackoff,
+		}))
+
+	go creds.Watch(ctx, a.PasswordFileCheckInterval, func(ctx context.Context) {
+		client := e.client.Load()
+		if client == nil {
+			return
+		}
+
+		err := client.Reauthenticate(ctx)
+		if err != nil {
+			log.Errorf("could not authenticate with changed credentials: %v", err)
+		}
+	})
+
+	return e, nil
+}
+
+// connect establishes the connection to the API unless it is already established
+func (e *engine) connect() error {
+	e.connectMutex.Lock()
+	defer e.connectMutex.Unlock()
+
+	if e.client.Load() != nil {
+		return nil
+	}
+
+	ctx, span := tracer.Start(e.ctx, "Engine.Connect", trace.WithAttributes(
+		attribute.String("engine", e.settings.Name),
+	))
+	defer span.End()
+
+	client, err := connectAPI(ctx, e.settings.API, e.creds, api.WithTokenObserver(e.observeToken))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	e.client.Store(client)
+	close(e.connected)
+
+	return nil
+}
+
+// connectInBackground retries to connect to the API until the connection is established
+func (e *engine) connectInBackground() {
+	ticker := time.NewTicker(connectRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := e.connect()
+		if err == nil {
+			log.Infof("Connected to engine %s", e.settings.Name)
+			return
+		}
+
+		log.Errorf("could not connect to engine %s: %v", e.settings.Name, err)
+	}
+}
+
+// stop stops all background tasks and terminates the session with the API
+func (e *engine) stop() {
+	e.cancel()
+
+	client := e.client.Load()
+	if client != nil {
+		client.Close()
+	}
+}
+
+// GetAndParse implements collector.Client interface
+func (e *engine) GetAndParse(ctx context.Context, path string, v interface{}) error {
+	client := e.client.Load()
+	if client == nil {
+		return errNotConnected
+	}
+
+	return client.GetAndParse(ctx, path, v)
+}
+
+// NameCaches implements namecache.Provider interface, so names of different engines are cached separately
+func (e *engine) NameCaches() *namecache.Set {
+	return e.names
+}
+
+// observeToken counts the attempts to obtain a SSO token on the engine, so the counters survive reconnects
+func (e *engine) observeToken(err error) {
+	if err != nil {
+		e.tokens.failures.Inc()
+		return
+	}
+
+	e.tokens.refreshes.Inc()
+}
+
+// labels returns the labels added to all metrics of the engine
+func (e *engine) labels() prometheus.Labels {
+	if e.settings.Name == "" {
+		return nil
+	}
+
+	return prometheus.Labels{"engine": e.settings.Name}
+}
+
+func newCollectorDurationHistogram() *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ovirt_collectors_duration",
+			Help:    "Histogram of latencies for metric collectors.",
+			Buckets: []float64{.1, .2, .4, 1, 3, 8, 20, 60},
+		},
+		[]string{"collector"},
+	)
+}
+
+func newCoalescedRequestsCounter() prometheus.Counter {
+	return prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ovirt_exporter_coalesced_requests_total",
+			Help: "Number of metric requests served by joining an already running collection.",
+		},
+	)
+}
+
+// tokenCounters count the attempts to obtain a SSO token from the engine
+type tokenCounters struct {
+	refreshes prometheus.Counter
+	failures  prometheus.Counter
+}
+
+func newTokenCounters() tokenCounters {
+	return tokenCounters{
+		refreshes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "ovirt_exporter_api_token_refreshes_total",
+			Help: "Number of SSO tokens obtained from the engine",
+		}),
+		failures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "ovirt_exporter_api_token_refresh_failures_total",
+			Help: "Number of failed attempts to obtain a SSO token from the engine",
+		}),
+	}
+}
+
+func connectAPI(ctx context.Context, a config.API, creds *credentials.Source, extraOpts ...api.ClientOption) (*api.Client, error) {
+	opts := []api.ClientOption{
+		api.WithRequestTimeout(a.Timeout),
+		api.WithConnectionPool(a.MaxIdleConnections, a.IdleConnectionTimeout),
+		api.WithAuthMethod(api.AuthMethod(a.AuthMethod)),
+	}
+	opts = append(opts, extraOpts...)
+
+	if *debug {
+		opts = append(opts, api.WithDebug())
+	}
+
+	if a.InsecureCert {
+		opts = append(opts, api.WithInsecure())
+	}
+
+	tlsOpts, err := apiTLSOptions(a)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, tlsOpts...)
+
+	opts = append(opts, api.WithPasswordFunc(creds.Load))
+
+	client, err := api.NewClient(ctx, a.URL, a.Username, "", opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return client, err
+}
+
+func apiTLSOptions(a config.API) ([]api.ClientOption, error) {
+	minVersion, err := api.ParseTLSVersion(a.TLSMinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []api.ClientOption{
+		api.WithMinTLSVersion(minVersion),
+	}
+
+	if a.CAFile != "" {
+		opts = append(opts, api.WithCAFile(a.CAFile))
+	}
+
+	if a.CAFetch {
+		opts = append(opts, api.WithCAFetch(a.CAFingerprint))
+	}
+
+	if a.ClientCertFile != "" {
+		opts = append(opts, api.WithClientCertificate(a.ClientCertFile, a.ClientKeyFile))
+	}
+
+	if a.ProxyURL != "" {
+		u, err := url.Parse(a.ProxyURL)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid proxy URL")
+		}
+
+		opts = append(opts, api.WithProxy(u))
+	}
+
+	return opts, nil
+}
+
+func backgroundRefresh() bool {
+	return *refreshInterval > 0 || *refreshInventory > 0 || *refreshStatistics > 0
+}
+
+// collectHostsAndStorage returns if this instance collects host and storage domain metrics
+func collectHostsAndStorage() bool {
+	return !*shardHostStorageFirst || shard.IsFirst()
+}
+
+func (e *engine) startPoller(ctx context.Context) (*poller.Poller, error) {
+	inventoryInterval, statisticsInterval, err := refreshIntervals()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Refreshing metrics in background (inventory: every %v, statistics: every %v)", inventoryInterval, statisticsInterval)
+
+	p := poller.New(e.cc)
+
+	if inventoryInterval == statisticsInterval {
+		e.addVMPollerTarget(p, "vm", inventoryInterval, collector.TierAll)
+	} else {
+		e.addVMPollerTarget(p, "vm_inventory", inventoryInterval, collector.TierInventory)
+		e.addVMPollerTarget(p, "vm_statistics", statisticsInterval, collector.TierStatistics)
+	}
+
+	if !collectHostsAndStorage() {
+		go e.runPoller(ctx, p)
+		return p, nil
+	}
+
+	if inventoryInterval == statisticsInterval {
+		e.addHostPollerTarget(p, "host", inventoryInterval, collector.TierAll)
+	} else {
+		e.addHostPollerTarget(p, "host_inventory", inventoryInterval, collector.TierInventory)
+		e.addHostPollerTarget(p, "host_statistics", statisticsInterval, collector.TierStatistics)
+	}
+
+	p.Add("storage", inventoryInterval, func(ctx context.Context, cc *collector.CollectorContext) prometheus.Collector {
+		return storagedomain.NewCollector(ctx, cc, e.storageConfig(), e.durations.WithLabelValues("storage"))
+	})
+
+	go e.runPoller(ctx, p)
+
+	return p, nil
+}
+
+// runPoller starts refreshing the collectors in background as soon as the connection to the API is established,
+// so the first refresh does not fail while the engine is still connecting
+func (e *engine) runPoller(ctx context.Context, p *poller.Poller) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-e.connected:
+	}
+
+	p.Run(ctx)
+}
+
+// refreshIntervals returns the intervals in which inventory and statistics are refreshed in background
+func refreshIntervals() (inventory, statistics time.Duration, err error) {
+	inventory = refreshIntervalOrDefault(*refreshInventory)
+	statistics = refreshIntervalOrDefault(*refreshStatistics)
+	if inventory <= 0 || statistics <= 0 {
+		return 0, 0, errors.New("refresh.interval has to be set if only one of refresh.inventory-interval and refresh.statistics-interval is set")
+	}
+
+	return inventory, statistics, nil
+}
+
+func refreshIntervalOrDefault(interval time.Duration) time.Duration {
+	if interval > 0 {
+		return interval
+	}
+
+	return *refreshInterval
+}
+
+func (e *engine) addVMPollerTarget(p *poller.Poller, name string, interval time.Duration, tier collector.Tier) {
+	p.Add(name, interval, func(ctx context.Context, cc *collector.CollectorContext) prometheus.Collector {
+		return vm.NewCollector(ctx, cc, e.vmConfig(tier), e.durations.WithLabelValues(name))
+	})
+}
+
+func (e *engine) addHostPollerTarget(p *poller.Poller, name string, interval time.Duration, tier collector.Tier) {
+	p.Add(name, interval, func(ctx context.Context, cc *collector.CollectorContext) prometheus.Collector {
+		return host.NewCollector(ctx, cc, e.hostConfig(tier), e.durations.WithLabelValues(name))
+	})
+}
+
+func (e *engine) vmConfig(tier collector.Tier) vm.Config {
+	return vm.Config{
+		Tier:              tier,
+		CollectStatistics: true,
+		CollectSnapshots:  e.settings.Collectors.Snapshots,
+		CollectNetwork:    e.settings.Collectors.Network,
+		CollectDisks:      e.settings.Collectors.Disks,
+		Prefetch:          e.settings.API.Prefetch,
+		Follow:            e.settings.API.Follow,
+		Shard:             shard,
+		Filter:            e.filters.vms,
+	}
+}
+
+func (e *engine) hostConfig(tier collector.Tier) host.Config {
+	return host.Config{
+		Tier:              tier,
+		CollectStatistics: true,
+		CollectNetwork:    e.settings.Collectors.Network,
+		Prefetch:          e.settings.API.Prefetch,
+		Follow:            e.settings.API.Follow,
+		Filter:            e.filters.hosts,
+	}
+}
+
+func (e *engine) storageConfig() storagedomain.Config {
+	return storagedomain.Config{
+		Filter: e.filters.storageDomains,
+	}
+}
+
+// collectMetrics collects the metrics of all objects of the engine in scope on demand using the selected collectors.
+// Sub collectors disabled by the settings of the engine stay disabled.
+func (e *engine) collectMetrics(ctx context.Context, scope collector.Scope, sel collector.Selection) ([]*dto.MetricFamily, error) {
+	reg := prometheus.NewRegistry()
+	r := prometheus.WrapRegistererWith(e.labels(), reg)
+
+	if e.client.Load() == nil {
+		return reg.Gather()
+	}
+
+	// prefetched lists contain all objects of the engine, so objects referenced by a scoped collection are resolved individually
+	prefetch := scope.IsZero()
+
+	vmCfg := e.vmConfig(collector.TierAll)
+	vmCfg.Search = scope.Search()
+	vmCfg.Prefetch = vmCfg.Prefetch && prefetch
+	vmCfg.CollectStatistics = sel.Enabled("vm_statistics")
+	vmCfg.CollectNetwork = vmCfg.CollectNetwork && sel.Enabled("vm_network")
+	vmCfg.CollectDisks = vmCfg.CollectDisks && sel.Enabled("vm_disks")
+	vmCfg.CollectSnapshots = vmCfg.CollectSnapshots && sel.Enabled("vm_snapshots")
+
+	hostCfg := e.hostConfig(collector.TierAll)
+	hostCfg.Search = scope.Search()
+	hostCfg.Prefetch = hostCfg.Prefetch && prefetch
+	hostCfg.CollectStatistics = sel.Enabled("host_statistics")
+	hostCfg.CollectNetwork = hostCfg.CollectNetwork && sel.Enabled("host_network")
+
+	storageCfg := e.storageConfig()
+	storageCfg.Search = scope.DataCenterSearch()
+
+	if sel.Enabled("vm") {
+		r.MustRegister(vm.NewCollector(ctx, e.cc.Clone(), vmCfg, e.durations.WithLabelValues("vm")))
+	}
+
+	if !collectHostsAndStorage() {
+		return reg.Gather()
+	}
+
+	if sel.Enabled("host") {
+		r.MustRegister(host.NewCollector(ctx, e.cc.Clone(), hostCfg, e.durations.WithLabelValues("host")))
+	}
+
+	if sel.Enabled("storage") {
+		r.MustRegister(storagedomain.NewCollector(ctx, e.cc.Clone(), storageCfg, e.durations.WithLabelValues("storage")))
+	}
+
+	return reg.Gather()
+}
+
+// gather collects the metrics of the engine in scope, joining a collection of the same scope and selection already running
+func (e *engine) gather(ctx context.Context, scope collector.Scope, sel collector.Selection) prometheus.Gatherer {
+	ctx, span := tracer.Start(ctx, "Engine.Gather", trace.WithAttributes(
+		attribute.String("engine", e.settings.Name),
+		attribute.String("scope", scope.String()),
+		attribute.String("collectors", sel.String()),
+	))
+	defer span.End()
+
+	key := scope.String() + ";collectors=" + sel.String()
+	mfs, shared, err := e.scrapes.Do(ctx, key, func(ctx context.Context) ([]*dto.MetricFamily, error) {
+		return e.collectMetrics(ctx, scope, sel)
+	})
+	span.SetAttributes(attribute.Bool("coalesced", shared))
+
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return mfs, err
+	})
+}
+
+// Describe implements Prometheus Collector interface
+func (e *engine) Describe(ch chan<- *prometheus.Desc) {
+	ch <- connectedDesc
+	e.creds.Describe(ch)
+	e.limiter.Describe(ch)
+	e.rateLimiter.Describe(ch)
+	e.breaker.Describe(ch)
+	e.coalesced.Describe(ch)
+	e.durations.Describe(ch)
+	e.tokens.refreshes.Describe(ch)
+	e.tokens.failures.Describe(ch)
+	e.names.Describe(ch)
+
+	if e.poller != nil {
+		e.poller.Describe(ch)
+	}
+}
+
+// Collect implements Prometheus Collector interface
+func (e *engine) Collect(ch chan<- prometheus.Metric) {
+	client := e.client.Load()
+	if client != nil {
+		ch <- prometheus.MustNewConstMetric(connectedDesc, prometheus.GaugeValue, 1)
+	} else {
+		ch <- prometheus.MustNewConstMetric(connectedDesc, prometheus.GaugeValue, 0)
+	}
+
+	e.creds.Collect(ch)
+	e.limiter.Collect(ch)
+	e.rateLimiter.Collect(ch)
+	e.breaker.Collect(ch)
+	e.coalesced.Collect(ch)
+	e.durations.Collect(ch)
+	if e.settings.API.AuthMethod == string(api.AuthOAuth) {
+		e.tokens.refreshes.Collect(ch)
+		e.tokens.failures.Collect(ch)
+	}
+	e.names.Collect(ch)
+
+	if e.poller != nil {
+		e.poller.Collect(ch)
+	}
+}
@@ -9,13 +9,16 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"strings"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
 
-	"github.com/czerwonk/ovirt_api/api"
 	"github.com/czerwonk/ovirt_exporter/pkg/collector"
-	"github.com/czerwonk/ovirt_exporter/pkg/host"
-	"github.com/czerwonk/ovirt_exporter/pkg/storagedomain"
-	"github.com/czerwonk/ovirt_exporter/pkg/vm"
+	"github.com/czerwonk/ovirt_exporter/pkg/config"
+	"github.com/czerwonk/ovirt_exporter/pkg/metric"
+	"github.com/czerwonk/ovirt_exporter/pkg/namecache"
+	"github.com/czerwonk/ovirt_exporter/pkg/statistic"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
@@ -27,16 +30,52 @@ const version string = "0.10.2"
 
 var (
 	showVersion              = flag.Bool("version", false, "Print version information.")
+	configFile               = flag.String("config.file", "", "Path to the YAML configuration file (settings of flags set explicitly take precedence)")
 	listenAddress            = flag.String("web.listen-address", ":9325", "Address on which to expose metrics and web interface.")
 	metricsPath              = flag.String("web.telemetry-path", "/metrics", "Path under which to expose metrics.")
 	apiURL                   = flag.String("api.url", "https://localhost/ovirt-engine/api/", "API REST Endpoint")
 	apiUser                  = flag.String("api.username", "user@internal", "API username")
 	apiPass                  = flag.String("api.password", "", "API password")
-	apiPassFile              = flag.String("api.password-file", "", "File containing the API password")
+	apiPassFile              = flag.String("api.password-file", "", "File containing the API password (default: -api.password or environment variable OVIRT_EXPORTER_API_PASSWORD)")
+	apiPassFileInterval      = flag.Duration("api.password-file-check-interval", 30*time.Second, "Interval the password file is checked for changes (0 = disabled)")
+	apiAuthMethod            = flag.String("api.auth-method", "oauth", "Method used to authenticate against the API (oauth, basic)")
 	apiInsecureCert          = flag.Bool("api.insecure-cert", false, "Skip verification for untrusted SSL/TLS certificates")
+	apiCAFile                = flag.String("api.ca-file", "", "File containing the CA certificates trusted for the API (PEM)")
+	apiCAFetch               = flag.Bool("api.ca-fetch", false, "Retrieve the CA certificate from the engine if the CA file does not exist (the certificate is written to the CA file)")
+	apiCAFingerprint         = flag.String("api.ca-fingerprint", "", "SHA-256 fingerprint the CA certificate retrieved from the engine is verified against")
+	apiClientCertFile        = flag.String("api.client-cert-file", "", "Client certificate used to authenticate TLS connections to the API (PEM)")
+	apiClientKeyFile         = flag.String("api.client-key-file", "", "Private key of the client certificate (PEM)")
+	apiTLSMinVersion         = flag.String("api.tls-min-version", "1.2", "Minimum TLS version accepted for connections to the API (1.0, 1.1, 1.2, 1.3)")
+	apiProxyURL              = flag.String("api.proxy-url", "", "HTTP(S) proxy used for connections to the API (default: proxy environment variables)")
+	apiTimeout               = flag.Duration("api.timeout", time.Minute, "Timeout for a single request to the API (0 = no timeout)")
+	apiMaxIdleConns          = flag.Int("api.max-idle-connections", 32, "Maximum number of idle connections to the API kept open for reuse")
+	apiIdleConnTimeout       = flag.Duration("api.idle-connection-timeout", 90*time.Second, "Time after an idle connection to the API is closed")
+	apiMaxConcurrent         = flag.Int("api.max-concurrent-requests", 0, "Maximum number of concurrent requests to the API shared by all collectors (0 = unlimited)")
+	apiRateLimit             = flag.Float64("api.rate-limit", 0, "Maximum number of requests per second sent to the API (0 = unlimited)")
+	apiRateLimitBurst        = flag.Int("api.rate-limit-burst", 10, "Number of requests allowed to exceed the rate limit in bursts")
+	apiRateLimitLatency      = flag.Duration("api.rate-limit-latency-threshold", 5*time.Second, "Response time of the API considered as overload, reducing the effective rate limit (0 = ignore latency)")
+	apiRetries               = flag.Int("api.retries", 2, "Number of retries for API requests failing with transient errors")
+	apiRetryBackoff          = flag.Duration("api.retry-backoff", 500*time.Millisecond, "Initial backoff before retrying a failed API request (doubled for every retry, randomized)")
+	apiRetryMaxBackoff       = flag.Duration("api.retry-max-backoff", 10*time.Second, "Maximum backoff between retries of a failed API request")
+	apiBreakerThreshold      = flag.Int("api.circuit-breaker-threshold", 10, "Number of consecutive failed API requests opening the circuit breaker (0 = disabled)")
+	apiBreakerCooldown       = flag.Duration("api.circuit-breaker-cooldown", 30*time.Second, "Time the circuit breaker stays open before probing the API again")
 	withSnapshots            = flag.Bool("with-snapshots", true, "Collect snapshot metrics (can be time consuming in some cases)")
 	withNetwork              = flag.Bool("with-network", true, "Collect network metrics (can be time consuming in some cases)")
 	withDisks                = flag.Bool("with-disks", true, "Collect disk metrics (can be time consuming in some cases)")
+	metricsAllow             = flag.String("metrics.allow", "", "Comma separated list of metric names (globs or regular expressions enclosed in slashes) exported by the collectors (default: all)")
+	metricsDeny              = flag.String("metrics.deny", "", "Comma separated list of metric names (globs or regular expressions enclosed in slashes) not exported by the collectors")
+	metricsLegacyStatNames   = flag.Bool("metrics.legacy-statistic-names", false, "Name metrics of statistics after the names and units reported by the engine without unit conversion (names of previous versions)")
+	metricsLabelMode         = flag.String("metrics.label-mode", "names", "Labels identifying VMs, hosts and storage domains (names: names of the object and related objects, ids: IDs only, names are exported by info metrics)")
+	refreshInterval          = flag.Duration("refresh.interval", 0, "Interval in which metrics are collected in the background and served from memory (0 = collect on every scrape)")
+	refreshInventory         = flag.Duration("refresh.inventory-interval", 0, "Interval in which inventory (vms, hosts, diskattachments, snapshots, storagedomains) is refreshed in the background (defaults to refresh.interval)")
+	refreshStatistics        = flag.Duration("refresh.statistics-interval", 0, "Interval in which statistics (VM, host and NIC statistics) are refreshed in the background (defaults to refresh.interval)")
+	shardFlag                = flag.String("shard", "", "Shard of VMs collected by this instance in the form index/count (e.g. 0/3) when running multiple instances against the same engine")
+	shardHostStorageFirst    = flag.Bool("shard.host-storage-first-only", false, "Collect host and storage domain metrics on the first shard only")
+	prefetch                 = flag.Bool("api.prefetch", true, "Retrieve hosts, clusters, storage domains and disks referenced by VMs as lists once per collection instead of requesting each object")
+	follow                   = flag.Bool("api.follow", false, "Retrieve statistics, NICs, disk attachments and snapshots embedded in the VM and host lists (requires oVirt 4.2 or newer)")
+	nameCacheTTL             = flag.Duration("cache.name-ttl", time.Hour, "Time to live for cached names of hosts, clusters and storage domains")
+	nameCacheErrorTTL        = flag.Duration("cache.name-error-ttl", time.Minute, "Time until a failed name lookup of a host, cluster or storage domain is retried")
+	probeMaxEngines          = flag.Int("probe.max-engines", 100, "Maximum number of engines kept open for probes, probes of further targets are rejected (0 = unlimited)")
 	debug                    = flag.Bool("debug", false, "Show verbose output (e.g. body of each response received from API)")
 	tlsEnabled               = flag.Bool("tls.enabled", false, "Enables TLS")
 	tlsCertChainPath         = flag.String("tls.cert-file", "", "Path to TLS cert file")
@@ -45,14 +84,7 @@ var (
 	tracingProvider          = flag.String("tracing.provider", "", "Sets the tracing provider (stdout or collector)")
 	tracingCollectorEndpoint = flag.String("tracing.collector.grpc-endpoint", "", "Sets the tracing provider (stdout or collector)")
 
-	collectorDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "ovirt_collectors_duration",
-			Help:    "Histogram of latencies for metric collectors.",
-			Buckets: []float64{.1, .2, .4, 1, 3, 8, 20, 60},
-		},
-		[]string{"collector"},
-	)
+	shard = collector.Shard{Index: 0, Count: 1}
 )
 
 func init() {
@@ -71,16 +103,46 @@ func main() {
 		os.Exit(0)
 	}
 
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	if *debug {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	if *shardFlag != "" {
+		var err error
+		shard, err = collector.ParseShard(*shardFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if backgroundRefresh() {
+		_, _, err := refreshIntervals()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	labelMode, err := metric.ParseLabelMode(*metricsLabelMode)
+	if err != nil {
+		log.Fatal(err)
+	}
+	metric.SetLabelMode(labelMode)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	shutdownTracing, err := initTracing(ctx)
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatalf("could not load configuration: %v", err)
+	}
+
+	shutdownTracing, err := initTracing(ctx, cfg.Tracing)
 	if err != nil {
 		log.Fatalf("could not initialize tracing: %v", err)
 	}
 	defer shutdownTracing()
 
-	startServer()
+	startServer(ctx, cfg)
 }
 
 func printVersion() {
@@ -90,7 +152,7 @@ func printVersion() {
 	fmt.Println("Metric exporter for oVirt engine")
 }
 
-func startServer() {
+func startServer(ctx context.Context, cfg *config.Config) {
 	log.Infof("Starting oVirt exporter (Version: %s)", version)
 
 	http.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
@@ -105,83 +167,108 @@ func startServer() {
 			</html>`))
 	})
 
-	client, err := connectAPI()
+	namecache.Configure(*nameCacheTTL, *nameCacheErrorTTL)
+	statistic.Configure(*metricsLegacyStatNames)
+
+	r, err := newReloader(ctx, cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer client.Close()
+	defer r.stop()
+
+	go r.watchSignals()
+	http.HandleFunc("/-/reload", r.handleReloadRequest)
+	http.HandleFunc("/probe", r.handleProbeRequest)
 
 	reg := prometheus.NewRegistry()
 	reg.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
 	reg.MustRegister(collectors.NewGoCollector())
-	reg.MustRegister(collectorDuration)
+	reg.MustRegister(shardInfo())
+	reg.MustRegister(r)
+
+	http.HandleFunc(*metricsPath, func(w http.ResponseWriter, req *http.Request) {
+		// metrics refreshed in background contain all objects, so scoped requests are collected on demand
+		if backgroundRefresh() && requestScope(req).IsZero() && !selectsCollectors(req) {
+			handleCachedMetricsRequest(w, req, reg)
+			return
+		}
 
-	http.HandleFunc(*metricsPath, func(w http.ResponseWriter, r *http.Request) {
-		handleMetricsRequest(w, r, client, reg)
+		handleMetricsRequest(w, req, r.current(), reg)
 	})
 
 	log.Infof("Listening for %s on %s (TLS: %v)", *metricsPath, *listenAddress, *tlsEnabled)
-	if *tlsEnabled {
-		log.Fatal(http.ListenAndServeTLS(*listenAddress, *tlsCertChainPath, *tlsKeyPath, nil))
-		return
+	err = serve(ctx)
+	if err != nil {
+		log.Error(err)
 	}
-
-	log.Fatal(http.ListenAndServe(*listenAddress, nil))
 }
 
-func connectAPI() (*api.Client, error) {
-	opts := []api.ClientOption{api.WithLogger(log.StandardLogger())}
+// serve handles requests until the context is cancelled
+func serve(ctx context.Context) error {
+	srv := &http.Server{Addr: *listenAddress}
 
-	if *debug {
-		opts = append(opts, api.WithDebug())
-	}
+	go func() {
+		<-ctx.Done()
+		log.Info("Shutting down")
 
-	if *apiInsecureCert {
-		opts = append(opts, api.WithInsecure())
-	}
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
 
-	pass, err := apiPassword()
-	if err != nil {
-		return nil, errors.Wrap(err, "error while reading password file")
+	var err error
+	if *tlsEnabled {
+		err = srv.ListenAndServeTLS(*tlsCertChainPath, *tlsKeyPath)
+	} else {
+		err = srv.ListenAndServe()
 	}
 
-	client, err := api.NewClient(*apiURL, *apiUser, pass, opts...)
-	if err != nil {
-		return nil, err
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
 	}
 
-	return client, err
+	return err
 }
 
-func apiPassword() (string, error) {
-	if *apiPassFile == "" {
-		return *apiPass, nil
-	}
-
-	b, err := os.ReadFile(*apiPassFile)
-	if err != nil {
-		return "", err
-	}
+func handleCachedMetricsRequest(w http.ResponseWriter, r *http.Request, appReg *prometheus.Registry) {
+	l := log.New()
+	l.Level = log.ErrorLevel
 
-	return strings.Trim(string(b), "\n"), nil
+	promhttp.HandlerFor(appReg, promhttp.HandlerOpts{
+		ErrorLog:      l,
+		ErrorHandling: promhttp.ContinueOnError,
+		Registry:      appReg}).ServeHTTP(w, r)
 }
 
-func handleMetricsRequest(w http.ResponseWriter, r *http.Request, client *api.Client, appReg *prometheus.Registry) {
-	ctx, span := tracer.Start(r.Context(), "HandleMetricsRequest")
+func handleMetricsRequest(w http.ResponseWriter, r *http.Request, engines []*engine, appReg *prometheus.Registry) {
+	ctx, cancel := scrapeContext(r)
+	defer cancel()
+
+	ctx, span := tracer.Start(ctx, "HandleMetricsRequest")
 	defer span.End()
 
-	reg := prometheus.NewRegistry()
+	multiRegs := make(prometheus.Gatherers, len(engines)+1)
+	multiRegs[len(engines)] = appReg
+
+	sel, err := requestSelection(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	cc := collector.NewContext(tracer, client)
-	reg.MustRegister(vm.NewCollector(ctx, cc.Clone(), *withSnapshots, *withNetwork, *withDisks, collectorDuration.WithLabelValues("vm")))
-	reg.MustRegister(host.NewCollector(ctx, cc.Clone(), *withNetwork, collectorDuration.WithLabelValues("host")))
-	reg.MustRegister(storagedomain.NewCollector(ctx, cc.Clone(), collectorDuration.WithLabelValues("storage")))
+	scope := requestScope(r)
+	wg := &sync.WaitGroup{}
+	wg.Add(len(engines))
 
-	multiRegs := prometheus.Gatherers{
-		reg,
-		appReg,
+	for i, e := range engines {
+		go func() {
+			defer wg.Done()
+			multiRegs[i] = e.gather(ctx, scope, sel)
+		}()
 	}
 
+	wg.Wait()
+
 	l := log.New()
 	l.Level = log.ErrorLevel
 
@@ -190,3 +277,49 @@ func handleMetricsRequest(w http.ResponseWriter, r *http.Request, client *api.Cl
 		ErrorHandling: promhttp.ContinueOnError,
 		Registry:      appReg}).ServeHTTP(w, r)
 }
+
+func shardInfo() prometheus.Gauge {
+	g := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ovirt_exporter_shard_info",
+		Help: "Shard of VMs collected by this instance",
+		ConstLabels: prometheus.Labels{
+			"shard":  strconv.Itoa(shard.Index),
+			"shards": strconv.Itoa(shard.Count),
+		},
+	})
+	g.Set(1)
+
+	return g
+}
+
+// requestScope returns the scope defined by the parameters cluster and datacenter of the request
+func requestScope(r *http.Request) collector.Scope {
+	q := r.URL.Query()
+
+	return collector.Scope{
+		Cluster:    q.Get("cluster"),
+		DataCenter: q.Get("datacenter"),
+	}
+}
+
+// requestSelection returns the collectors selected by the parameters collect[] and exclude[] of the request
+func requestSelection(r *http.Request) (collector.Selection, error) {
+	q := r.URL.Query()
+	return collector.ParseSelection(q["collect[]"], q["exclude[]"])
+}
+
+// selectsCollectors returns if the request restricts the collectors by the parameters collect[] or exclude[]
+func selectsCollectors(r *http.Request) bool {
+	q := r.URL.Query()
+	return q.Has("collect[]") || q.Has("exclude[]")
+}
+
+// scrapeContext derives a context from the request which is cancelled after the scrape timeout sent by Prometheus
+func scrapeContext(r *http.Request) (context.Context, context.CancelFunc) {
+	timeout, err := strconv.ParseFloat(r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"), 64)
+	if err != nil || timeout <= 0 {
+		return context.WithCancel(r.Context())
+	}
+
+	return context.WithTimeout(r.Context(), time.Duration(timeout*float64(time.Second)))
+}
@@ -0,0 +1,301 @@
+// SPDX-License-Identifier: MIT
+
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// AuthMethod defines how the client authenticates against the API
+type AuthMethod string
+
+const (
+	// AuthBasic authenticates using HTTP basic authentication
+	AuthBasic AuthMethod = "basic"
+
+	// AuthOAuth authenticates using a bearer token issued by the SSO service of the engine
+	AuthOAuth AuthMethod = "oauth"
+)
+
+// ParseAuthMethod converts the name of an authentication method to AuthMethod
+func ParseAuthMethod(s string) (AuthMethod, error) {
+	m := AuthMethod(s)
+	if m != AuthBasic && m != AuthOAuth {
+		return "", fmt.Errorf("unsupported authentication method: %s", s)
+	}
+
+	return m, nil
+}
+
+// Client encapsulates communication with the oVirt REST API
+type Client struct {
+	url             string
+	username        string
+	password        string
+	passwordFunc    PasswordFunc
+	authMethod      AuthMethod
+	debug           bool
+	insecure        bool
+	caFile          string
+	fetchCA         bool
+	caFingerprint   string
+	clientCertFile  string
+	clientKeyFile   string
+	minTLSVersion   uint16
+	proxy           *url.URL
+	requestTimeout  time.Duration
+	maxIdleConns    int
+	idleConnTimeout time.Duration
+	client          *http.Client
+	authMutex       sync.Mutex
+	sessionMutex    sync.RWMutex
+	sess            *session
+	tokenObserver   TokenObserver
+}
+
+// ClientOption applies options to Client
+type ClientOption func(*Client)
+
+// WithInsecure disables TLS certificate validation
+func WithInsecure() ClientOption {
+	return func(c *Client) {
+		c.insecure = true
+	}
+}
+
+// WithDebug enables debug mode (logging the body of each response)
+func WithDebug() ClientOption {
+	return func(c *Client) {
+		c.debug = true
+	}
+}
+
+// WithRequestTimeout sets the timeout for a single request (0 = no timeout)
+func WithRequestTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.requestTimeout = timeout
+	}
+}
+
+// WithConnectionPool sets the number of idle connections kept open and the time after they are closed
+func WithConnectionPool(maxIdleConns int, idleConnTimeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.maxIdleConns = maxIdleConns
+		c.idleConnTimeout = idleConnTimeout
+	}
+}
+
+// PasswordFunc returns the current password used to authenticate against the API
+type PasswordFunc func(ctx context.Context) (string, error)
+
+// WithPasswordFunc obtains the password from f every time the client authenticates, allowing the password to change at runtime
+func WithPasswordFunc(f PasswordFunc) ClientOption {
+	return func(c *Client) {
+		c.passwordFunc = f
+	}
+}
+
+// WithAuthMethod sets the method used to authenticate against the API
+func WithAuthMethod(m AuthMethod) ClientOption {
+	return func(c *Client) {
+		c.authMethod = m
+	}
+}
+
+// NewClient returns a new client and establishes a session with the API
+func NewClient(ctx context.Context, url, username, password string, opts ...ClientOption) (*Client, error) {
+	c := &Client{
+		url:             strings.TrimRight(url, "/"),
+		username:        username,
+		password:        password,
+		authMethod:      AuthOAuth,
+		maxIdleConns:    32,
+		idleConnTimeout: 90 * time.Second,
+		minTLSVersion:   tls.VersionTLS12,
+	}
+
+	for _, o := range opts {
+		o(c)
+	}
+
+	_, err := ParseAuthMethod(string(c.authMethod))
+	if err != nil {
+		return nil, err
+	}
+
+	tr, err := c.transport(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.client = &http.Client{
+		Transport: tr,
+	}
+
+	s, err := c.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.sess = s
+
+	return c, nil
+}
+
+func (c *Client) transport(ctx context.Context) (*http.Transport, error) {
+	cfg, err := c.tlsConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tr := c.baseTransport()
+	tr.MaxIdleConns = c.maxIdleConns
+	tr.MaxIdleConnsPerHost = c.maxIdleConns
+	tr.IdleConnTimeout = c.idleConnTimeout
+	tr.TLSClientConfig = cfg
+
+	return tr, nil
+}
+
+func (c *Client) baseTransport() *http.Transport {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+
+	if c.proxy != nil {
+		tr.Proxy = http.ProxyURL(c.proxy)
+	}
+
+	return tr
+}
+
+// GetAndParse retrieves XML data from the API and unmarshals it
+func (c *Client) GetAndParse(ctx context.Context, path string, v interface{}) error {
+	b, err := c.Get(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	return xml.Unmarshal(b, v)
+}
+
+// Get retrieves XML data from the API and returns it
+func (c *Client) Get(ctx context.Context, path string) ([]byte, error) {
+	if c.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.requestTimeout)
+		defer cancel()
+	}
+
+	s := c.session()
+	if s.expiring() {
+		err := c.renewSession(ctx, s, "token expires soon")
+		if err != nil {
+			log.Errorf("could not refresh token before expiry: %v", err)
+		}
+
+		s = c.session()
+	}
+
+	b, err := c.get(ctx, path, s)
+
+	var se *StatusError
+	if errors.As(err, &se) && se.StatusCode == http.StatusUnauthorized {
+		err = c.renewSession(ctx, s, "session expired")
+		if err != nil {
+			return nil, err
+		}
+
+		return c.get(ctx, path, c.session())
+	}
+
+	return b, err
+}
+
+func (c *Client) get(ctx context.Context, path string, s *session) ([]byte, error) {
+	uri := c.url + "/" + strings.TrimLeft(path, "/")
+	log.Debugf("GET %s", uri)
+
+	req, err := c.newRequest(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s.apply(req)
+	req.Header.Set("Prefer", "persistent-auth")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	log.Debugf("Status Code: %s", resp.Status)
+
+	if resp.StatusCode >= 300 {
+		io.Copy(io.Discard, resp.Body)
+		return nil, &StatusError{Method: req.Method, Path: path, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read response for %s: %w", path, err)
+	}
+
+	if c.debug {
+		log.Debugf("Response: %s", string(b))
+	}
+
+	return b, nil
+}
+
+// newRequest creates a request to the engine carrying the trace context of ctx
+func (c *Client) newRequest(ctx context.Context, method, uri string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, uri, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/xml")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	return req, nil
+}
+
+// Close terminates the session with the API
+func (c *Client) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	s := c.session()
+	if s.token != "" {
+		c.revokeToken(ctx, s.token)
+		return
+	}
+
+	req, err := c.newRequest(ctx, http.MethodHead, c.url, nil)
+	if err != nil {
+		return
+	}
+
+	// a request without persistent-auth ends the session
+	req.Header.Set("Cookie", s.cookie)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		log.Errorf("could not close API session: %v", err)
+		return
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,22 @@
+// SPDX-License-Identifier: MIT
+
+package api
+
+import "fmt"
+
+// StatusError is returned if the API responds with an unexpected HTTP status
+type StatusError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Method, e.Path, e.Status)
+}
+
+// Temporary returns if the request may succeed when retried
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode == 429 || e.StatusCode >= 500
+}
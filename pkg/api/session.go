@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: MIT
+
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// tokenRefreshMargin is the time before expiry a token is refreshed
+const tokenRefreshMargin = time.Minute
+
+// session holds the credentials used for requests to the API
+type session struct {
+	cookie  string
+	token   string
+	expires time.Time
+}
+
+// apply adds the session credentials to the request
+func (s *session) apply(req *http.Request) {
+	if s.cookie != "" {
+		req.Header.Set("Cookie", s.cookie)
+	}
+
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+}
+
+// expiring returns if the token expires soon
+func (s *session) expiring() bool {
+	return s.token != "" && !s.expires.IsZero() && time.Until(s.expires) < tokenRefreshMargin
+}
+
+func (c *Client) session() *session {
+	c.sessionMutex.RLock()
+	defer c.sessionMutex.RUnlock()
+
+	return c.sess
+}
+
+// renewSession authenticates again unless the expired session was already renewed by a concurrent request
+func (c *Client) renewSession(ctx context.Context, expired *session, reason string) error {
+	c.authMutex.Lock()
+	defer c.authMutex.Unlock()
+
+	if c.session() != expired {
+		return nil
+	}
+
+	log.Infof("Authenticating again (%s)", reason)
+	s, err := c.authenticate(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.sessionMutex.Lock()
+	c.sess = s
+	c.sessionMutex.Unlock()
+
+	if expired.token != "" {
+		c.revokeToken(ctx, expired.token)
+	}
+
+	return nil
+}
+
+// Reauthenticate establishes a new session, e.g. after the credentials have changed
+func (c *Client) Reauthenticate(ctx context.Context) error {
+	return c.renewSession(ctx, c.session(), "credentials changed")
+}
+
+func (c *Client) currentPassword(ctx context.Context) string {
+	if c.passwordFunc == nil {
+		return c.password
+	}
+
+	p, err := c.passwordFunc(ctx)
+	if err != nil {
+		log.Errorf("could not load current API password, using the last known one: %v", err)
+	}
+
+	return p
+}
+
+// authenticate obtains credentials and establishes a persistent session with the API
+func (c *Client) authenticate(ctx context.Context) (*session, error) {
+	s := &session{}
+	password := c.currentPassword(ctx)
+
+	if c.authMethod == AuthOAuth {
+		t, err := c.requestToken(ctx, password)
+		if err != nil {
+			return nil, err
+		}
+
+		s.token = t.accessToken
+		s.expires = t.expires
+	}
+
+	req, err := c.newRequest(ctx, http.MethodHead, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.token == "" {
+		req.SetBasicAuth(c.username, password)
+	}
+	s.apply(req)
+	req.Header.Set("Prefer", "persistent-auth")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{Method: req.Method, Path: c.url, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	s.cookie = strings.Split(resp.Header.Get("Set-Cookie"), ";")[0]
+	return s, nil
+}
@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: MIT
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ssoTokenPath  = "/ovirt-engine/sso/oauth/token"
+	ssoLogoutPath = "/ovirt-engine/services/sso-logout"
+	ssoScope      = "ovirt-app-api"
+)
+
+type token struct {
+	accessToken string
+	expires     time.Time
+}
+
+type tokenResponse struct {
+	AccessToken      string          `json:"access_token"`
+	Exp              json.RawMessage `json:"exp"`
+	Error            string          `json:"error"`
+	ErrorDescription string          `json:"error_description"`
+}
+
+// TokenObserver is called after each attempt to obtain a SSO token with the error of the attempt (nil on success)
+type TokenObserver func(err error)
+
+// WithTokenObserver reports each attempt to obtain a SSO token to f, including the attempts establishing the session
+func WithTokenObserver(f TokenObserver) ClientOption {
+	return func(c *Client) {
+		c.tokenObserver = f
+	}
+}
+
+// ssoURL returns the URL of a SSO service of the engine the API belongs to
+func (c *Client) ssoURL(path string) (*url.URL, error) {
+	u, err := url.Parse(c.url)
+	if err != nil {
+		return nil, err
+	}
+
+	return &url.URL{Scheme: u.Scheme, Host: u.Host, Path: path}, nil
+}
+
+// requestToken obtains a new bearer token from the SSO service of the engine
+func (c *Client) requestToken(ctx context.Context, password string) (*token, error) {
+	t, err := c.doRequestToken(ctx, password)
+	if c.tokenObserver != nil {
+		c.tokenObserver(err)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not obtain SSO token: %w", err)
+	}
+
+	return t, nil
+}
+
+func (c *Client) doRequestToken(ctx context.Context, password string) (*token, error) {
+	u, err := c.ssoURL(ssoTokenPath)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "password")
+	form.Set("scope", ssoScope)
+	form.Set("username", c.username)
+	form.Set("password", password)
+
+	req, err := c.newRequest(ctx, http.MethodPost, u.String(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	tr := tokenResponse{}
+	err = json.Unmarshal(b, &tr)
+	if err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("could not parse response: %w", err)
+	}
+
+	if tr.Error != "" {
+		return nil, fmt.Errorf("%s: %s", tr.Error, tr.ErrorDescription)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{Method: req.Method, Path: ssoTokenPath, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	if tr.AccessToken == "" {
+		return nil, fmt.Errorf("response contains no access token")
+	}
+
+	return &token{
+		accessToken: tr.AccessToken,
+		expires:     parseExpiry(tr.Exp),
+	}, nil
+}
+
+// parseExpiry parses the expiry of a token (milliseconds since epoch, either as string or number).
+// A zero time is returned if the token does not expire.
+func parseExpiry(raw json.RawMessage) time.Time {
+	s := strings.Trim(string(raw), `"`)
+	if s == "" {
+		return time.Time{}
+	}
+
+	ms, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || ms <= 0 || ms == math.MaxInt64 {
+		return time.Time{}
+	}
+
+	return time.UnixMilli(ms)
+}
+
+// revokeToken ends the SSO session of the token
+func (c *Client) revokeToken(ctx context.Context, t string) {
+	u, err := c.ssoURL(ssoLogoutPath)
+	if err != nil {
+		return
+	}
+	u.RawQuery = url.Values{"scope": []string{""}, "token": []string{t}}.Encode()
+
+	req, err := c.newRequest(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		log.Errorf("could not revoke SSO token: %v", err)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Errorf("could not revoke SSO token: %s", resp.Status)
+	}
+}
@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: MIT
+
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestAuthenticationRequestsCarryTraceContext(t *testing.T) {
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(prev)
+
+	mutex := sync.Mutex{}
+	traceparents := map[string]string{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		traceparents[r.Method+" "+r.URL.Path] = r.Header.Get("traceparent")
+		mutex.Unlock()
+
+		if r.URL.Path == ssoTokenPath {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token":"token","exp":"0"}`))
+		}
+	}))
+	defer srv.Close()
+
+	traceID := trace.TraceID{1, 2, 3}
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	c, err := NewClient(ctx, srv.URL+"/ovirt-engine/api", "admin@internal", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for _, req := range []string{"POST " + ssoTokenPath, "HEAD /ovirt-engine/api"} {
+		if !strings.Contains(traceparents[req], traceID.String()) {
+			t.Errorf("expected %s to carry trace %s, got traceparent %q", req, traceID, traceparents[req])
+		}
+	}
+}
+
+func TestTokenFailuresAreObserved(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"access_denied","error_description":"invalid credentials"}`))
+	}))
+	defer srv.Close()
+
+	var observed []error
+	_, err := NewClient(context.Background(), srv.URL+"/ovirt-engine/api", "admin@internal", "wrong",
+		WithTokenObserver(func(err error) {
+			observed = append(observed, err)
+		}))
+	if err == nil {
+		t.Fatal("expected authentication to fail")
+	}
+
+	if len(observed) != 1 || observed[0] == nil {
+		t.Fatalf("expected a single failed token request to be observed, got %v", observed)
+	}
+}
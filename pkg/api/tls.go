@@ -0,0 +1,206 @@
+// SPDX-License-Identifier: MIT
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const caCertificatePath = "/ovirt-engine/services/pki-resource"
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseTLSVersion converts a TLS version (e.g. 1.2) to its numeric representation
+func ParseTLSVersion(s string) (uint16, error) {
+	if v, found := tlsVersions[s]; found {
+		return v, nil
+	}
+
+	return 0, fmt.Errorf("unsupported TLS version: %s", s)
+}
+
+// WithCAFile sets the file containing the CA certificates trusted for the engine (PEM).
+// Certificates of the system are not trusted if set.
+func WithCAFile(path string) ClientOption {
+	return func(c *Client) {
+		c.caFile = path
+	}
+}
+
+// WithCAFetch retrieves the CA certificate from the engine if no CA file exists.
+// The certificate is verified against the SHA-256 fingerprint (if not empty) and written to the CA file (if set).
+func WithCAFetch(fingerprint string) ClientOption {
+	return func(c *Client) {
+		c.fetchCA = true
+		c.caFingerprint = fingerprint
+	}
+}
+
+// WithClientCertificate authenticates the TLS connection with a client certificate
+func WithClientCertificate(certFile, keyFile string) ClientOption {
+	return func(c *Client) {
+		c.clientCertFile = certFile
+		c.clientKeyFile = keyFile
+	}
+}
+
+// WithMinTLSVersion sets the minimum TLS version accepted for connections to the engine
+func WithMinTLSVersion(v uint16) ClientOption {
+	return func(c *Client) {
+		c.minTLSVersion = v
+	}
+}
+
+// WithProxy sends all requests via the given HTTP(S) proxy instead of using the proxy environment variables
+func WithProxy(u *url.URL) ClientOption {
+	return func(c *Client) {
+		c.proxy = u
+	}
+}
+
+func (c *Client) tlsConfig(ctx context.Context) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:         c.minTLSVersion,
+		InsecureSkipVerify: c.insecure,
+	}
+
+	if c.caFile != "" || c.fetchCA {
+		pool, err := c.certPool(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.RootCAs = pool
+	}
+
+	if c.clientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.clientCertFile, c.clientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %w", err)
+		}
+
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+func (c *Client) certPool(ctx context.Context) (*x509.CertPool, error) {
+	b, err := os.ReadFile(c.caFile)
+	if c.caFile == "" || (errors.Is(err, fs.ErrNotExist) && c.fetchCA) {
+		b, err = c.fetchCACertificate(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not load CA certificate: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no valid CA certificate found")
+	}
+
+	return pool, nil
+}
+
+// fetchCACertificate retrieves the CA certificate from the engine and pins it if a CA file is set
+func (c *Client) fetchCACertificate(ctx context.Context) ([]byte, error) {
+	u, err := c.ssoURL(caCertificatePath)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = "resource=ca-certificate&format=X509-PEM-CA"
+
+	req, err := c.newRequest(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// the certificate of the engine can not be verified before its CA is known
+	tr := c.baseTransport()
+	tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+
+	resp, err := (&http.Client{Transport: tr}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{Method: req.Method, Path: caCertificatePath, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err = c.verifiedCertificate(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.caFile != "" {
+		err = os.WriteFile(c.caFile, b, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("could not write CA file: %w", err)
+		}
+
+		log.Infof("Pinned CA certificate of the engine in %s", c.caFile)
+	}
+
+	return b, nil
+}
+
+// verifiedCertificate returns the PEM encoded CA certificate after verifying its fingerprint.
+// Responses containing anything but a single certificate are rejected, so no other certificate can be trusted along with the verified one.
+func (c *Client) verifiedCertificate(b []byte) ([]byte, error) {
+	block, rest := pem.Decode(b)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("response contains no PEM encoded certificate")
+	}
+
+	if len(bytes.TrimSpace(rest)) > 0 {
+		return nil, fmt.Errorf("response contains more than a single CA certificate")
+	}
+
+	_, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CA certificate: %w", err)
+	}
+
+	sum := sha256.Sum256(block.Bytes)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	if c.caFingerprint == "" {
+		log.Warnf("Trusting CA certificate of the engine without verification (SHA-256 fingerprint: %s)", fingerprint)
+		return pem.EncodeToMemory(block), nil
+	}
+
+	expected := strings.ToLower(strings.ReplaceAll(c.caFingerprint, ":", ""))
+	if fingerprint != expected {
+		return nil, fmt.Errorf("fingerprint of CA certificate %s does not match %s", fingerprint, expected)
+	}
+
+	return pem.EncodeToMemory(block), nil
+}
@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: MIT
+
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func testCertificate(t *testing.T, name string) ([]byte, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(der)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), hex.EncodeToString(sum[:])
+}
+
+func TestVerifiedCertificate(t *testing.T) {
+	pinned, fingerprint := testCertificate(t, "pinned")
+	other, _ := testCertificate(t, "other")
+
+	tests := []struct {
+		name        string
+		fingerprint string
+		response    []byte
+		valid       bool
+	}{
+		{
+			name:        "pinned certificate",
+			fingerprint: fingerprint,
+			response:    pinned,
+			valid:       true,
+		},
+		{
+			name:        "fingerprint with colons",
+			fingerprint: fingerprint[:2] + ":" + fingerprint[2:],
+			response:    pinned,
+			valid:       true,
+		},
+		{
+			name:     "without fingerprint",
+			response: pinned,
+			valid:    true,
+		},
+		{
+			name:        "other certificate",
+			fingerprint: fingerprint,
+			response:    other,
+		},
+		{
+			name:        "additional certificate after pinned one",
+			fingerprint: fingerprint,
+			response:    append(append([]byte{}, pinned...), other...),
+		},
+		{
+			name:        "trailing data",
+			fingerprint: fingerprint,
+			response:    append(append([]byte{}, pinned...), []byte("garbage")...),
+		},
+		{
+			name:        "no certificate",
+			fingerprint: fingerprint,
+			response:    []byte("not a certificate"),
+		},
+		{
+			name:        "private key",
+			fingerprint: fingerprint,
+			response:    pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("x")}),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &Client{caFingerprint: test.fingerprint}
+
+			b, err := c.verifiedCertificate(test.response)
+			if !test.valid {
+				if err == nil {
+					t.Error("expected response to be rejected")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(b) != string(pinned) {
+				t.Errorf("got certificate %q, expected %q", b, pinned)
+			}
+		})
+	}
+}
@@ -2,8 +2,16 @@
 
 package cluster
 
+// Clusters is a collection of clusters
+type Clusters struct {
+	Clusters []Cluster `xml:"cluster"`
+}
+
 type Cluster struct {
 	ID          string `xml:"id,attr"`
 	Name        string `xml:"name"`
 	Description string `xml:"description"`
+	DataCenter  struct {
+		ID string `xml:"id,attr"`
+	} `xml:"data_center"`
 }
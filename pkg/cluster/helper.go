@@ -4,19 +4,15 @@ package cluster
 
 import (
 	"context"
-	"sync"
 
 	"fmt"
 
 	"github.com/czerwonk/ovirt_exporter/pkg/collector"
+	"github.com/czerwonk/ovirt_exporter/pkg/datacenter"
+	"github.com/czerwonk/ovirt_exporter/pkg/namecache"
 	log "github.com/sirupsen/logrus"
 )
 
-var (
-	cacheMutex = sync.Mutex{}
-	nameCache  = make(map[string]string)
-)
-
 // Get retrieves cluster information
 func Get(ctx context.Context, id string, cl collector.Client) (*Cluster, error) {
 	path := fmt.Sprintf("clusters/%s", id)
@@ -32,19 +28,59 @@ func Get(ctx context.Context, id string, cl collector.Client) (*Cluster, error)
 
 // Name retrieves cluster name
 func Name(ctx context.Context, id string, cl collector.Client) string {
-	cacheMutex.Lock()
-	defer cacheMutex.Unlock()
+	n, _ := namecache.For(cl, "cluster").Get(ctx, id, func(ctx context.Context, id string) (string, error) {
+		c, err := Get(ctx, id, cl)
+		if err != nil {
+			log.Error(err)
+			return "", err
+		}
+
+		return c.Name, nil
+	})
+
+	return n
+}
+
+// DataCenterName retrieves the name of the data center the cluster belongs to
+func DataCenterName(ctx context.Context, id string, cl collector.Client) string {
+	if id == "" {
+		return ""
+	}
 
-	if n, found := nameCache[id]; found {
-		return n
+	dcID, _ := namecache.For(cl, "cluster_data_center").Get(ctx, id, func(ctx context.Context, id string) (string, error) {
+		c, err := Get(ctx, id, cl)
+		if err != nil {
+			log.Error(err)
+			return "", err
+		}
+
+		return c.DataCenter.ID, nil
+	})
+
+	return datacenter.Name(ctx, dcID, cl)
+}
+
+// UpdateNames updates the cached names and data centers with clusters retrieved by a list call
+func UpdateNames(clusters []Cluster, cl collector.Client) {
+	names := make(map[string]string, len(clusters))
+	dataCenters := make(map[string]string, len(clusters))
+	for _, c := range clusters {
+		names[c.ID] = c.Name
+		dataCenters[c.ID] = c.DataCenter.ID
 	}
 
-	h, err := Get(ctx, id, cl)
+	namecache.For(cl, "cluster").Update(names)
+	namecache.For(cl, "cluster_data_center").Update(dataCenters)
+}
+
+// List retrieves all clusters
+func List(ctx context.Context, cl collector.Client) ([]Cluster, error) {
+	c := Clusters{}
+	err := cl.GetAndParse(ctx, "clusters", &c)
 	if err != nil {
-		log.Error(err)
-		return ""
+		return nil, err
 	}
 
-	nameCache[id] = h.Name
-	return h.Name
+	UpdateNames(c.Clusters, cl)
+	return c.Clusters, nil
 }
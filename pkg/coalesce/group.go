@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: MIT
+
+package coalesce
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// GatherFunc gathers metric families
+type GatherFunc func(ctx context.Context) ([]*dto.MetricFamily, error)
+
+// Group coalesces concurrent gatherings with the same key into a single execution
+type Group struct {
+	mutex     sync.Mutex
+	calls     map[string]*call
+	coalesced prometheus.Counter
+}
+
+type call struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+	result  []*dto.MetricFamily
+	err     error
+}
+
+// NewGroup creates a new group. Requests joining a running gathering are counted by coalesced.
+func NewGroup(coalesced prometheus.Counter) *Group {
+	return &Group{
+		calls:     make(map[string]*call),
+		coalesced: coalesced,
+	}
+}
+
+// Do executes fn for the given key. If a gathering for the key is already in flight,
+// the caller waits for it and shares its result. The context passed to fn is cancelled
+// as soon as all waiting callers gave up. The returned bool reports if the result was shared.
+func (g *Group) Do(ctx context.Context, key string, fn GatherFunc) ([]*dto.MetricFamily, bool, error) {
+	g.mutex.Lock()
+	c, found := g.calls[key]
+	if found {
+		c.waiters++
+		g.mutex.Unlock()
+		g.coalesced.Inc()
+
+		mfs, err := g.wait(ctx, key, c)
+		return mfs, true, err
+	}
+
+	callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	c = &call{
+		done:    make(chan struct{}),
+		cancel:  cancel,
+		waiters: 1,
+	}
+	g.calls[key] = c
+	g.mutex.Unlock()
+
+	go func() {
+		defer cancel()
+
+		c.result, c.err = fn(callCtx)
+
+		g.mutex.Lock()
+		if g.calls[key] == c {
+			delete(g.calls, key)
+		}
+		g.mutex.Unlock()
+
+		close(c.done)
+	}()
+
+	mfs, err := g.wait(ctx, key, c)
+	return mfs, false, err
+}
+
+func (g *Group) wait(ctx context.Context, key string, c *call) ([]*dto.MetricFamily, error) {
+	select {
+	case <-c.done:
+		return c.result, c.err
+	case <-ctx.Done():
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	c.waiters--
+	if c.waiters == 0 {
+		c.cancel()
+
+		if g.calls[key] == c {
+			delete(g.calls, key)
+		}
+	}
+
+	return nil, ctx.Err()
+}
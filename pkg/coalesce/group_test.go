@@ -0,0 +1,272 @@
+// SPDX-License-Identifier: MIT
+
+package coalesce
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+type result struct {
+	mfs    []*dto.MetricFamily
+	shared bool
+	err    error
+}
+
+func newTestGroup() (*Group, prometheus.Counter) {
+	coalesced := prometheus.NewCounter(prometheus.CounterOpts{Name: "coalesced_total"})
+	return NewGroup(coalesced), coalesced
+}
+
+// waitFor waits until cond is met or fails the test after a second
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func do(ctx context.Context, g *Group, key string, fn GatherFunc) <-chan result {
+	ch := make(chan result, 1)
+	go func() {
+		mfs, shared, err := g.Do(ctx, key, fn)
+		ch <- result{mfs: mfs, shared: shared, err: err}
+	}()
+
+	return ch
+}
+
+func receive(t *testing.T, ch <-chan result) result {
+	t.Helper()
+
+	select {
+	case r := <-ch:
+		return r
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for result")
+		return result{}
+	}
+}
+
+func TestConcurrentCallersShareCall(t *testing.T) {
+	g, coalesced := newTestGroup()
+
+	const callers = 5
+	var calls atomic.Int32
+	release := make(chan struct{})
+	expected := []*dto.MetricFamily{{}}
+
+	fn := func(ctx context.Context) ([]*dto.MetricFamily, error) {
+		calls.Add(1)
+		<-release
+		return expected, nil
+	}
+
+	results := []<-chan result{do(context.Background(), g, "key", fn)}
+	waitFor(t, "call to start", func() bool { return calls.Load() == 1 })
+
+	for i := 1; i < callers; i++ {
+		results = append(results, do(context.Background(), g, "key", fn))
+	}
+	waitFor(t, "callers to join", func() bool { return testutil.ToFloat64(coalesced) == callers-1 })
+
+	close(release)
+
+	shared := 0
+	for _, ch := range results {
+		r := receive(t, ch)
+		if r.err != nil {
+			t.Fatal(r.err)
+		}
+
+		if len(r.mfs) != 1 || r.mfs[0] != expected[0] {
+			t.Errorf("expected result of the shared call, got %v", r.mfs)
+		}
+
+		if r.shared {
+			shared++
+		}
+	}
+
+	if calls.Load() != 1 {
+		t.Errorf("expected 1 call, got %d", calls.Load())
+	}
+
+	if shared != callers-1 {
+		t.Errorf("expected %d shared results, got %d", callers-1, shared)
+	}
+}
+
+func TestDifferentKeysAreNotShared(t *testing.T) {
+	g, coalesced := newTestGroup()
+
+	var calls atomic.Int32
+	fn := func(ctx context.Context) ([]*dto.MetricFamily, error) {
+		calls.Add(1)
+		return nil, nil
+	}
+
+	for _, key := range []string{"a", "b", "a"} {
+		_, shared, err := g.Do(context.Background(), key, fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if shared {
+			t.Errorf("expected call for %s not to be shared", key)
+		}
+	}
+
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 calls, got %d", calls.Load())
+	}
+
+	if v := testutil.ToFloat64(coalesced); v != 0 {
+		t.Errorf("expected no coalesced requests, got %v", v)
+	}
+}
+
+func TestCancelledWaiter(t *testing.T) {
+	tests := []struct {
+		name   string
+		cancel int
+	}{
+		{name: "caller starting the call", cancel: 0},
+		{name: "joining caller", cancel: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g, coalesced := newTestGroup()
+
+			started := make(chan struct{})
+			release := make(chan struct{})
+			var callCtx context.Context
+			fn := func(ctx context.Context) ([]*dto.MetricFamily, error) {
+				callCtx = ctx
+				close(started)
+				<-release
+				return []*dto.MetricFamily{{}}, ctx.Err()
+			}
+
+			contexts := make([]context.Context, 3)
+			cancels := make([]context.CancelFunc, 3)
+			for i := range contexts {
+				contexts[i], cancels[i] = context.WithCancel(context.Background())
+				defer cancels[i]()
+			}
+
+			results := []<-chan result{do(contexts[0], g, "key", fn)}
+			<-started
+
+			for _, ctx := range contexts[1:] {
+				results = append(results, do(ctx, g, "key", fn))
+			}
+			waitFor(t, "callers to join", func() bool { return testutil.ToFloat64(coalesced) == 2 })
+
+			cancels[test.cancel]()
+			r := receive(t, results[test.cancel])
+			if !errors.Is(r.err, context.Canceled) {
+				t.Errorf("expected cancelled caller to return %v, got %v", context.Canceled, r.err)
+			}
+
+			if callCtx.Err() != nil {
+				t.Fatal("expected call to continue while callers are waiting")
+			}
+
+			close(release)
+
+			for i, ch := range results {
+				if i == test.cancel {
+					continue
+				}
+
+				r := receive(t, ch)
+				if r.err != nil {
+					t.Errorf("caller %d: %v", i, r.err)
+				}
+
+				if len(r.mfs) != 1 {
+					t.Errorf("caller %d: expected result of the call, got %v", i, r.mfs)
+				}
+			}
+		})
+	}
+}
+
+func TestAllWaitersCancelled(t *testing.T) {
+	g, coalesced := newTestGroup()
+
+	var mutex sync.Mutex
+	var callContexts []context.Context
+	fn := func(ctx context.Context) ([]*dto.MetricFamily, error) {
+		mutex.Lock()
+		callContexts = append(callContexts, ctx)
+		mutex.Unlock()
+
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	calls := func() int {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		return len(callContexts)
+	}
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	defer cancel1()
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+
+	r1 := do(ctx1, g, "key", fn)
+	waitFor(t, "call to start", func() bool { return calls() == 1 })
+	r2 := do(ctx2, g, "key", fn)
+	waitFor(t, "caller to join", func() bool { return testutil.ToFloat64(coalesced) == 1 })
+
+	cancel1()
+	receive(t, r1)
+
+	mutex.Lock()
+	callCtx := callContexts[0]
+	mutex.Unlock()
+
+	if callCtx.Err() != nil {
+		t.Fatal("expected call to continue while a caller is waiting")
+	}
+
+	cancel2()
+	receive(t, r2)
+
+	select {
+	case <-callCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected call to be cancelled after all callers gave up")
+	}
+
+	// the cancelled call must not be joined by later callers
+	ctx3, cancel3 := context.WithCancel(context.Background())
+	r3 := do(ctx3, g, "key", fn)
+	waitFor(t, "new call to start", func() bool { return calls() == 2 })
+	cancel3()
+
+	r := receive(t, r3)
+	if r.shared {
+		t.Error("expected new call not to join the cancelled one")
+	}
+}
@@ -0,0 +1,160 @@
+// SPDX-License-Identifier: MIT
+
+package collector
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrCircuitOpen is returned for requests rejected while the circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open, skipping API request")
+
+// BreakerState is the state of a circuit breaker
+type BreakerState int
+
+const (
+	// BreakerClosed lets all requests pass
+	BreakerClosed BreakerState = iota
+
+	// BreakerHalfOpen lets a single probe request pass after the cool-down period
+	BreakerHalfOpen
+
+	// BreakerOpen rejects all requests until the cool-down period is over
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerHalfOpen:
+		return "half_open"
+	case BreakerOpen:
+		return "open"
+	default:
+		return "closed"
+	}
+}
+
+var (
+	breakerStateDesc    = prometheus.NewDesc("ovirt_exporter_api_circuit_breaker_state", "State of the circuit breaker for API requests (0 = closed, 1 = half open, 2 = open)", nil, nil)
+	breakerRejectedDesc = prometheus.NewDesc("ovirt_exporter_api_circuit_breaker_rejected_requests_total", "Number of API requests rejected by the circuit breaker", nil, nil)
+	breakerOpenedDesc   = prometheus.NewDesc("ovirt_exporter_api_circuit_breaker_opened_total", "Number of times the circuit breaker for API requests was opened", nil, nil)
+)
+
+// CircuitBreaker stops sending requests to the API for a cool-down period after repeated failures
+type CircuitBreaker struct {
+	mutex         sync.Mutex
+	threshold     int
+	cooldown      time.Duration
+	failures      int
+	state         BreakerState
+	openedAt      time.Time
+	probeInFlight bool
+	rejected      float64
+	opened        float64
+}
+
+// NewCircuitBreaker creates a new circuit breaker opening after threshold consecutive failures (0 = disabled)
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+func (b *CircuitBreaker) enabled() bool {
+	return b.threshold > 0
+}
+
+// Allow returns ErrCircuitOpen if the request must not be sent
+func (b *CircuitBreaker) Allow() error {
+	if !b.enabled() {
+		return nil
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.state == BreakerOpen && now().Sub(b.openedAt) >= b.cooldown {
+		b.state = BreakerHalfOpen
+	}
+
+	if b.state == BreakerOpen || (b.state == BreakerHalfOpen && b.probeInFlight) {
+		b.rejected++
+		return ErrCircuitOpen
+	}
+
+	if b.state == BreakerHalfOpen {
+		b.probeInFlight = true
+	}
+
+	return nil
+}
+
+// Record updates the state by the result of a request allowed before
+func (b *CircuitBreaker) Record(err error) {
+	if !b.enabled() {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.probeInFlight = false
+
+	if isContextError(err) {
+		return
+	}
+
+	if !isTransient(err) {
+		b.failures = 0
+		b.state = BreakerClosed
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.open()
+	}
+}
+
+func (b *CircuitBreaker) open() {
+	if b.state != BreakerOpen {
+		b.opened++
+	}
+
+	b.state = BreakerOpen
+	b.openedAt = now()
+}
+
+// State returns the current state
+func (b *CircuitBreaker) State() BreakerState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.state
+}
+
+// Describe implements Prometheus Collector interface
+func (b *CircuitBreaker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- breakerStateDesc
+	ch <- breakerRejectedDesc
+	ch <- breakerOpenedDesc
+}
+
+// Collect implements Prometheus Collector interface
+func (b *CircuitBreaker) Collect(ch chan<- prometheus.Metric) {
+	if !b.enabled() {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(breakerStateDesc, prometheus.GaugeValue, float64(b.state))
+	ch <- prometheus.MustNewConstMetric(breakerRejectedDesc, prometheus.CounterValue, b.rejected)
+	ch <- prometheus.MustNewConstMetric(breakerOpenedDesc, prometheus.CounterValue, b.opened)
+}
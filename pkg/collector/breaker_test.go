@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: MIT
+
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/czerwonk/ovirt_exporter/pkg/api"
+)
+
+var (
+	errUnavailable = &api.StatusError{StatusCode: 503, Status: "503 Service Unavailable"}
+	errNotFound    = &api.StatusError{StatusCode: 404, Status: "404 Not Found"}
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	type request struct {
+		advance         time.Duration
+		err             error
+		pending         bool
+		expectedAllowed bool
+		expectedState   BreakerState
+	}
+
+	failed := request{err: errUnavailable, expectedAllowed: true, expectedState: BreakerClosed}
+	opening := request{err: errUnavailable, expectedAllowed: true, expectedState: BreakerOpen}
+	rejected := request{expectedAllowed: false, expectedState: BreakerOpen}
+
+	tests := []struct {
+		name             string
+		threshold        int
+		requests         []request
+		expectedRejected float64
+		expectedOpened   float64
+	}{
+		{
+			name:      "closed below threshold",
+			threshold: 3,
+			requests:  []request{failed, failed},
+		},
+		{
+			name:             "opened at threshold",
+			threshold:        3,
+			requests:         []request{failed, failed, opening, rejected},
+			expectedRejected: 1,
+			expectedOpened:   1,
+		},
+		{
+			name:      "successful request resets failures",
+			threshold: 3,
+			requests: []request{
+				failed, failed,
+				{expectedAllowed: true, expectedState: BreakerClosed},
+				failed, failed,
+			},
+		},
+		{
+			name:      "permanent error resets failures",
+			threshold: 3,
+			requests: []request{
+				failed, failed,
+				{err: errNotFound, expectedAllowed: true, expectedState: BreakerClosed},
+				failed, failed,
+			},
+		},
+		{
+			name:      "cancelled request ignored",
+			threshold: 3,
+			requests: []request{
+				failed, failed,
+				{err: context.Canceled, expectedAllowed: true, expectedState: BreakerClosed},
+				opening,
+			},
+			expectedOpened: 1,
+		},
+		{
+			name:      "rejected during cool-down",
+			threshold: 1,
+			requests: []request{
+				opening,
+				{advance: 59 * time.Second, expectedAllowed: false, expectedState: BreakerOpen},
+			},
+			expectedRejected: 1,
+			expectedOpened:   1,
+		},
+		{
+			name:      "half open after cool-down",
+			threshold: 1,
+			requests: []request{
+				opening,
+				{advance: time.Minute, pending: true, expectedAllowed: true, expectedState: BreakerHalfOpen},
+			},
+			expectedOpened: 1,
+		},
+		{
+			name:      "single probe while half open",
+			threshold: 1,
+			requests: []request{
+				opening,
+				{advance: time.Minute, pending: true, expectedAllowed: true, expectedState: BreakerHalfOpen},
+				{expectedAllowed: false, expectedState: BreakerHalfOpen},
+			},
+			expectedRejected: 1,
+			expectedOpened:   1,
+		},
+		{
+			name:      "closed after successful probe",
+			threshold: 3,
+			requests: []request{
+				failed, failed, opening,
+				{advance: time.Minute, expectedAllowed: true, expectedState: BreakerClosed},
+				failed, failed,
+			},
+			expectedOpened: 1,
+		},
+		{
+			name:      "opened again after failed probe",
+			threshold: 3,
+			requests: []request{
+				failed, failed, opening,
+				{advance: time.Minute, err: errUnavailable, expectedAllowed: true, expectedState: BreakerOpen},
+				rejected,
+			},
+			expectedRejected: 1,
+			expectedOpened:   2,
+		},
+		{
+			name:      "disabled",
+			threshold: 0,
+			requests:  []request{failed, failed, failed, failed},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			advance := fakeClock(t)
+			b := NewCircuitBreaker(test.threshold, time.Minute)
+
+			for i, r := range test.requests {
+				advance(r.advance)
+
+				err := b.Allow()
+				allowed := err == nil
+				if allowed != r.expectedAllowed {
+					t.Fatalf("request %d: expected allowed: %v, got %v", i, r.expectedAllowed, err)
+				}
+
+				if err != nil && !errors.Is(err, ErrCircuitOpen) {
+					t.Fatalf("request %d: expected %v, got %v", i, ErrCircuitOpen, err)
+				}
+
+				if allowed && !r.pending {
+					b.Record(r.err)
+				}
+
+				if state := b.State(); state != r.expectedState {
+					t.Fatalf("request %d: expected state %v, got %v", i, r.expectedState, state)
+				}
+			}
+
+			if b.rejected != test.expectedRejected {
+				t.Errorf("expected %v rejected requests, got %v", test.expectedRejected, b.rejected)
+			}
+
+			if b.opened != test.expectedOpened {
+				t.Errorf("expected breaker to be opened %v times, got %v", test.expectedOpened, b.opened)
+			}
+		})
+	}
+}
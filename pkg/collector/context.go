@@ -3,33 +3,88 @@
 package collector
 
 import (
-	"github.com/czerwonk/ovirt_api/api"
+	"sync/atomic"
+
+	"github.com/czerwonk/ovirt_exporter/pkg/metric"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 )
 
-func NewContext(tracer trace.Tracer, client *api.Client) *CollectorContext {
-	return &CollectorContext{
+// ContextOption applies options to CollectorContext
+type ContextOption func(*CollectorContext)
+
+// WithLimiter bounds the number of concurrent API requests using the given limiter
+func WithLimiter(l *Limiter) ContextOption {
+	return func(c *CollectorContext) {
+		c.client.limiter = l
+	}
+}
+
+// WithRateLimiter limits the rate of API requests using the given rate limiter
+func WithRateLimiter(r *RateLimiter) ContextOption {
+	return func(c *CollectorContext) {
+		c.client.rateLimiter = r
+	}
+}
+
+// WithCircuitBreaker stops sending API requests after repeated failures using the given circuit breaker
+func WithCircuitBreaker(b *CircuitBreaker) ContextOption {
+	return func(c *CollectorContext) {
+		c.client.breaker = b
+	}
+}
+
+// WithRetryPolicy retries API requests failing with transient errors according to the policy
+func WithRetryPolicy(p RetryPolicy) ContextOption {
+	return func(c *CollectorContext) {
+		c.client.retryPolicy = p
+	}
+}
+
+// WithMetricFilter drops metrics not allowed by the filter before they are recorded
+func WithMetricFilter(f *metric.Filter) ContextOption {
+	return func(c *CollectorContext) {
+		c.metricFilter = f
+	}
+}
+
+// NewContext creates a new context querying the API using the given client
+func NewContext(tracer trace.Tracer, client Client, opts ...ContextOption) *CollectorContext {
+	c := &CollectorContext{
 		tracer: tracer,
 		client: &clientTracingAdapter{
-			client: client,
-			tracer: tracer,
+			client:      client,
+			tracer:      tracer,
+			limiter:     NewLimiter(0),
+			rateLimiter: NewRateLimiter(0, 0, 0),
+			breaker:     NewCircuitBreaker(0, 0),
 		},
+		errors: &atomic.Int64{},
 	}
+
+	for _, o := range opts {
+		o(c)
+	}
+
+	return c
 }
 
 type CollectorContext struct {
-	tracer trace.Tracer
-	client *clientTracingAdapter
-	ch     chan<- prometheus.Metric
+	tracer       trace.Tracer
+	client       *clientTracingAdapter
+	ch           chan<- prometheus.Metric
+	errors       *atomic.Int64
+	metricFilter *metric.Filter
 }
 
 func (c *CollectorContext) Clone() *CollectorContext {
 	return &CollectorContext{
-		tracer: c.tracer,
-		client: c.client,
+		tracer:       c.tracer,
+		client:       c.client,
+		errors:       &atomic.Int64{},
+		metricFilter: c.metricFilter,
 	}
 }
 
@@ -48,16 +103,35 @@ func (c *CollectorContext) SetMetricsCh(ch chan<- prometheus.Metric) {
 	c.ch = ch
 }
 
-// RecordMetrics returns the collected metrics to the collector
+// MetricAllowed returns if the metric with the given name is exported, so metrics not allowed do not have to be created at all
+func (c *CollectorContext) MetricAllowed(name string) bool {
+	return c.metricFilter.Allows(name)
+}
+
+// RecordMetrics returns the collected metrics allowed by the metric filter to the collector
 func (c *CollectorContext) RecordMetrics(metrics ...prometheus.Metric) {
 	for _, m := range metrics {
-		c.ch <- m
+		if c.descAllowed(m.Desc()) {
+			c.ch <- m
+		}
 	}
 }
 
+// descAllowed returns if metrics of the descriptor are exported. Descriptors not created by metric.NewDesc are always exported.
+func (c *CollectorContext) descAllowed(d *prometheus.Desc) bool {
+	name, found := metric.DescName(d)
+	return !found || c.metricFilter.Allows(name)
+}
+
 // HandleError handles an error
 func (c *CollectorContext) HandleError(err error, span trace.Span) {
+	c.errors.Add(1)
 	logrus.Error(err)
 	span.RecordError(err)
 	span.SetStatus(codes.Error, err.Error())
 }
+
+// ErrorCount returns the number of errors handled since the context was created
+func (c *CollectorContext) ErrorCount() int64 {
+	return c.errors.Load()
+}
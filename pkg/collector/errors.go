@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: MIT
+
+package collector
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/czerwonk/ovirt_exporter/pkg/api"
+)
+
+// statusCode returns the HTTP status code of an error returned by the API client (0 if not caused by a HTTP response)
+func statusCode(err error) int {
+	var se *api.StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode
+	}
+
+	return 0
+}
+
+// isServerError returns if the error was caused by an 5xx response of the API
+func isServerError(err error) bool {
+	status := statusCode(err)
+	return status >= 500 && status < 600
+}
+
+// isContextError returns if the error was caused by a cancelled or timed out context
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// isTransient returns if the error is likely to be temporary, so retrying the request may succeed
+func isTransient(err error) bool {
+	if err == nil || isContextError(err) {
+		return false
+	}
+
+	var se *api.StatusError
+	if errors.As(err, &se) {
+		return se.Temporary()
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
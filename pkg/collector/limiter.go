@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: MIT
+
+package collector
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Limiter bounds the number of concurrent requests to the API
+type Limiter struct {
+	slots    chan struct{}
+	queued   prometheus.Gauge
+	inFlight prometheus.Gauge
+	wait     prometheus.Histogram
+}
+
+// NewLimiter creates a new limiter allowing max concurrent requests (0 = unlimited)
+func NewLimiter(max int) *Limiter {
+	l := &Limiter{
+		queued: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "ovirt_exporter_api_requests_queued",
+			Help: "Number of API requests waiting for a free slot",
+		}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "ovirt_exporter_api_requests_in_flight",
+			Help: "Number of API requests currently running",
+		}),
+		wait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "ovirt_exporter_api_request_wait_seconds",
+			Help:    "Histogram of the time API requests waited for a free slot",
+			Buckets: []float64{.001, .01, .05, .1, .5, 1, 5, 15, 60},
+		}),
+	}
+
+	if max > 0 {
+		l.slots = make(chan struct{}, max)
+	}
+
+	return l
+}
+
+// Acquire waits for a free slot. Release has to be called after the request if no error is returned.
+func (l *Limiter) Acquire(ctx context.Context) (time.Duration, error) {
+	if l.slots == nil {
+		l.inFlight.Inc()
+		return 0, nil
+	}
+
+	start := time.Now()
+	l.queued.Inc()
+	defer l.queued.Dec()
+
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return time.Since(start), ctx.Err()
+	}
+
+	waited := time.Since(start)
+	l.wait.Observe(waited.Seconds())
+	l.inFlight.Inc()
+
+	return waited, nil
+}
+
+// Release frees the slot acquired before
+func (l *Limiter) Release() {
+	l.inFlight.Dec()
+
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+// Describe implements Prometheus Collector interface
+func (l *Limiter) Describe(ch chan<- *prometheus.Desc) {
+	l.queued.Describe(ch)
+	l.inFlight.Describe(ch)
+	l.wait.Describe(ch)
+}
+
+// Collect implements Prometheus Collector interface
+func (l *Limiter) Collect(ch chan<- prometheus.Metric) {
+	l.queued.Collect(ch)
+	l.inFlight.Collect(ch)
+	l.wait.Collect(ch)
+}
@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: MIT
+
+package collector
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// rateDecreaseFactor is applied to the effective rate when the engine seems to be overloaded
+	rateDecreaseFactor = 0.5
+
+	// rateIncreaseSteps is the number of healthy responses needed to recover from minimum to maximum rate
+	rateIncreaseSteps = 100
+
+	// minRateDivisor defines the lower bound of the effective rate relative to the configured one
+	minRateDivisor = 10
+
+	// decreaseInterval prevents concurrent slow responses from reducing the rate multiple times at once
+	decreaseInterval = time.Second
+)
+
+var (
+	rateDesc = prometheus.NewDesc("ovirt_exporter_api_rate_limit_requests_per_second", "Current effective rate limit for API requests", nil, nil)
+
+	// now returns the current time, replaced in tests to control time dependent behaviour
+	now = time.Now
+)
+
+// RateLimiter limits the rate of API requests using a token bucket.
+// The effective rate is reduced when the engine responds slowly or with server errors and recovers gradually afterwards.
+type RateLimiter struct {
+	mutex            sync.Mutex
+	maxRate          float64
+	minRate          float64
+	rate             float64
+	burst            float64
+	tokens           float64
+	last             time.Time
+	lastDecrease     time.Time
+	latencyThreshold time.Duration
+}
+
+// NewRateLimiter creates a new rate limiter allowing qps requests per second (0 = unlimited) with the given burst.
+// The rate is reduced if responses take longer than latencyThreshold (0 = ignore latency).
+func NewRateLimiter(qps float64, burst int, latencyThreshold time.Duration) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &RateLimiter{
+		maxRate:          qps,
+		minRate:          qps / minRateDivisor,
+		rate:             qps,
+		burst:            float64(burst),
+		tokens:           float64(burst),
+		last:             now(),
+		latencyThreshold: latencyThreshold,
+	}
+}
+
+func (r *RateLimiter) enabled() bool {
+	return r.maxRate > 0
+}
+
+// Wait blocks until a request is allowed to be sent
+func (r *RateLimiter) Wait(ctx context.Context) error {
+	if !r.enabled() {
+		return nil
+	}
+
+	delay := r.reserve()
+	if delay == 0 {
+		return nil
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		r.cancelReservation()
+		return ctx.Err()
+	}
+}
+
+func (r *RateLimiter) reserve() time.Duration {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.refill(now())
+	r.tokens--
+
+	if r.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-r.tokens / r.rate * float64(time.Second))
+}
+
+func (r *RateLimiter) cancelReservation() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.tokens = math.Min(r.tokens+1, r.burst)
+}
+
+func (r *RateLimiter) refill(t time.Time) {
+	elapsed := t.Sub(r.last).Seconds()
+	r.last = t
+	r.tokens = math.Min(r.tokens+elapsed*r.rate, r.burst)
+}
+
+// Observe adapts the effective rate to the latency and result of a request
+func (r *RateLimiter) Observe(latency time.Duration, err error) {
+	if !r.enabled() {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	t := now()
+	r.refill(t)
+
+	overloaded := isServerError(err) || (r.latencyThreshold > 0 && latency > r.latencyThreshold)
+	if !overloaded {
+		r.rate = math.Min(r.maxRate, r.rate+(r.maxRate-r.minRate)/rateIncreaseSteps)
+		return
+	}
+
+	if t.Sub(r.lastDecrease) < decreaseInterval {
+		return
+	}
+
+	r.lastDecrease = t
+	r.rate = math.Max(r.minRate, r.rate*rateDecreaseFactor)
+}
+
+// Rate returns the current effective rate
+func (r *RateLimiter) Rate() float64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.rate
+}
+
+// Describe implements Prometheus Collector interface
+func (r *RateLimiter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- rateDesc
+}
+
+// Collect implements Prometheus Collector interface
+func (r *RateLimiter) Collect(ch chan<- prometheus.Metric) {
+	if !r.enabled() {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(rateDesc, prometheus.GaugeValue, r.Rate())
+}
@@ -0,0 +1,243 @@
+// SPDX-License-Identifier: MIT
+
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/czerwonk/ovirt_exporter/pkg/api"
+)
+
+// fakeClock replaces the clock of the package until the test ends and returns a function advancing it
+func fakeClock(t *testing.T) func(time.Duration) {
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return clock }
+	t.Cleanup(func() { now = time.Now })
+
+	return func(d time.Duration) {
+		clock = clock.Add(d)
+	}
+}
+
+func TestRateLimiterTokenBucket(t *testing.T) {
+	type reservation struct {
+		advance       time.Duration
+		expectedDelay time.Duration
+	}
+
+	tests := []struct {
+		name         string
+		qps          float64
+		burst        int
+		reservations []reservation
+	}{
+		{
+			name:  "burst allowed immediately",
+			qps:   10,
+			burst: 3,
+			reservations: []reservation{
+				{expectedDelay: 0},
+				{expectedDelay: 0},
+				{expectedDelay: 0},
+				{expectedDelay: 100 * time.Millisecond},
+			},
+		},
+		{
+			name:  "waiting requests queue up",
+			qps:   10,
+			burst: 1,
+			reservations: []reservation{
+				{expectedDelay: 0},
+				{expectedDelay: 100 * time.Millisecond},
+				{expectedDelay: 200 * time.Millisecond},
+			},
+		},
+		{
+			name:  "tokens refilled by rate",
+			qps:   10,
+			burst: 1,
+			reservations: []reservation{
+				{expectedDelay: 0},
+				{advance: 100 * time.Millisecond, expectedDelay: 0},
+				{advance: 50 * time.Millisecond, expectedDelay: 50 * time.Millisecond},
+			},
+		},
+		{
+			name:  "refill limited to burst",
+			qps:   10,
+			burst: 2,
+			reservations: []reservation{
+				{expectedDelay: 0},
+				{expectedDelay: 0},
+				{advance: time.Minute, expectedDelay: 0},
+				{expectedDelay: 0},
+				{expectedDelay: 100 * time.Millisecond},
+			},
+		},
+		{
+			name:  "burst of at least 1",
+			qps:   10,
+			burst: 0,
+			reservations: []reservation{
+				{expectedDelay: 0},
+				{expectedDelay: 100 * time.Millisecond},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			advance := fakeClock(t)
+			r := NewRateLimiter(test.qps, test.burst, 0)
+
+			for i, res := range test.reservations {
+				advance(res.advance)
+
+				delay := r.reserve()
+				if (delay - res.expectedDelay).Abs() > time.Microsecond {
+					t.Errorf("reservation %d: expected delay %v, got %v", i, res.expectedDelay, delay)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterCancelledWaitReturnsToken(t *testing.T) {
+	fakeClock(t)
+	r := NewRateLimiter(1, 1, 0)
+
+	err := r.Wait(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = r.Wait(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+
+	if delay := r.reserve(); delay != time.Second {
+		t.Errorf("expected token of cancelled request to be returned (delay 1s), got delay %v", delay)
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	r := NewRateLimiter(0, 1, time.Second)
+
+	for i := 0; i < 100; i++ {
+		err := r.Wait(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r.Observe(time.Minute, &api.StatusError{StatusCode: 503})
+	}
+
+	if rate := r.Rate(); rate != 0 {
+		t.Errorf("expected rate of disabled limiter to stay 0, got %v", rate)
+	}
+}
+
+func TestRateLimiterObserve(t *testing.T) {
+	type observation struct {
+		advance time.Duration
+		latency time.Duration
+		err     error
+		times   int
+	}
+
+	slow := observation{advance: decreaseInterval, latency: 2 * time.Second}
+	healthy := observation{latency: 100 * time.Millisecond}
+
+	tests := []struct {
+		name             string
+		latencyThreshold time.Duration
+		observations     []observation
+		expectedRate     float64
+	}{
+		{
+			name:             "healthy response at maximum rate",
+			latencyThreshold: time.Second,
+			observations:     []observation{healthy},
+			expectedRate:     100,
+		},
+		{
+			name:             "slow response",
+			latencyThreshold: time.Second,
+			observations:     []observation{slow},
+			expectedRate:     50,
+		},
+		{
+			name:             "latency ignored without threshold",
+			latencyThreshold: 0,
+			observations:     []observation{slow},
+			expectedRate:     100,
+		},
+		{
+			name:             "server error",
+			latencyThreshold: time.Second,
+			observations:     []observation{{err: &api.StatusError{StatusCode: 503}}},
+			expectedRate:     50,
+		},
+		{
+			name:             "client error",
+			latencyThreshold: time.Second,
+			observations:     []observation{{err: &api.StatusError{StatusCode: 404}}},
+			expectedRate:     100,
+		},
+		{
+			name:             "reduced once per interval",
+			latencyThreshold: time.Second,
+			observations:     []observation{slow, {latency: 2 * time.Second, times: 5}},
+			expectedRate:     50,
+		},
+		{
+			name:             "reduced again after interval",
+			latencyThreshold: time.Second,
+			observations:     []observation{slow, slow},
+			expectedRate:     25,
+		},
+		{
+			name:             "not below minimum rate",
+			latencyThreshold: time.Second,
+			observations:     []observation{{advance: decreaseInterval, latency: 2 * time.Second, times: 10}},
+			expectedRate:     10,
+		},
+		{
+			name:             "recovers gradually",
+			latencyThreshold: time.Second,
+			observations:     []observation{slow, {latency: 100 * time.Millisecond, times: 10}},
+			expectedRate:     59,
+		},
+		{
+			name:             "recovers up to maximum rate",
+			latencyThreshold: time.Second,
+			observations:     []observation{slow, {latency: 100 * time.Millisecond, times: rateIncreaseSteps}},
+			expectedRate:     100,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			advance := fakeClock(t)
+			r := NewRateLimiter(100, 1, test.latencyThreshold)
+
+			for _, o := range test.observations {
+				for i := 0; i < max(o.times, 1); i++ {
+					advance(o.advance)
+					r.Observe(o.latency, o.err)
+				}
+			}
+
+			if rate := r.Rate(); rate < test.expectedRate-0.001 || rate > test.expectedRate+0.001 {
+				t.Errorf("expected rate %v, got %v", test.expectedRate, rate)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: MIT
+
+package collector
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy defines how requests failing with transient errors are retried
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of retries per request (0 = no retries)
+	MaxRetries int
+
+	// Backoff is the upper bound of the delay before the first retry, doubled for every further retry
+	Backoff time.Duration
+
+	// MaxBackoff limits the delay between retries
+	MaxBackoff time.Duration
+}
+
+// shouldRetry returns if a request failed with err in the given attempt (starting with 0) should be retried
+func (p RetryPolicy) shouldRetry(attempt int, err error) bool {
+	return attempt < p.MaxRetries && isTransient(err)
+}
+
+// wait sleeps for a jittered exponential backoff before the next attempt
+func (p RetryPolicy) wait(ctx context.Context, attempt int) error {
+	t := time.NewTimer(p.delay(attempt))
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.Backoff << attempt
+	if d <= 0 || (p.MaxBackoff > 0 && d > p.MaxBackoff) {
+		d = p.MaxBackoff
+	}
+
+	if d <= 0 {
+		return 0
+	}
+
+	return rand.N(d)
+}
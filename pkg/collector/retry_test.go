@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: MIT
+
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/czerwonk/ovirt_exporter/pkg/api"
+)
+
+func TestShouldRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempt  int
+		err      error
+		expected bool
+	}{
+		{name: "no error", err: nil},
+		{name: "too many requests", err: &api.StatusError{StatusCode: 429}, expected: true},
+		{name: "internal server error", err: &api.StatusError{StatusCode: 500}, expected: true},
+		{name: "bad gateway", err: &api.StatusError{StatusCode: 502}, expected: true},
+		{name: "service unavailable", err: errUnavailable, expected: true},
+		{name: "wrapped service unavailable", err: fmt.Errorf("could not get vms: %w", errUnavailable), expected: true},
+		{name: "bad request", err: &api.StatusError{StatusCode: 400}},
+		{name: "unauthorized", err: &api.StatusError{StatusCode: 401}},
+		{name: "not found", err: errNotFound},
+		{name: "connection refused", err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, expected: true},
+		{name: "unexpected end of response", err: fmt.Errorf("could not read response: %w", io.ErrUnexpectedEOF), expected: true},
+		{name: "cancelled", err: context.Canceled},
+		{name: "deadline exceeded", err: fmt.Errorf("request failed: %w", context.DeadlineExceeded)},
+		{name: "circuit open", err: ErrCircuitOpen},
+		{name: "other error", err: errors.New("could not parse response")},
+		{name: "last retry", attempt: 1, err: errUnavailable, expected: true},
+		{name: "retries exhausted", attempt: 2, err: errUnavailable},
+	}
+
+	p := RetryPolicy{MaxRetries: 2}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if retry := p.shouldRetry(test.attempt, test.err); retry != test.expected {
+				t.Errorf("expected retry: %v, got %v", test.expected, retry)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     RetryPolicy
+		attempt    int
+		upperBound time.Duration
+	}{
+		{
+			name:       "first retry",
+			policy:     RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second},
+			attempt:    0,
+			upperBound: 100 * time.Millisecond,
+		},
+		{
+			name:       "doubled for further retries",
+			policy:     RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second},
+			attempt:    2,
+			upperBound: 400 * time.Millisecond,
+		},
+		{
+			name:       "limited by maximum backoff",
+			policy:     RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second},
+			attempt:    5,
+			upperBound: time.Second,
+		},
+		{
+			name:       "limited by maximum backoff on overflow",
+			policy:     RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second},
+			attempt:    70,
+			upperBound: time.Second,
+		},
+		{
+			name:       "unlimited backoff",
+			policy:     RetryPolicy{Backoff: 100 * time.Millisecond},
+			attempt:    5,
+			upperBound: 3200 * time.Millisecond,
+		},
+		{
+			name:       "no backoff",
+			policy:     RetryPolicy{},
+			attempt:    3,
+			upperBound: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				d := test.policy.delay(test.attempt)
+				if d < 0 || d > test.upperBound || (test.upperBound > 0 && d == test.upperBound) {
+					t.Fatalf("expected delay in [0, %v), got %v", test.upperBound, d)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryWaitCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := RetryPolicy{Backoff: time.Hour, MaxBackoff: time.Hour}
+	err := p.wait(ctx, 0)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: MIT
+
+package collector
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Scope restricts the collected objects to a cluster and/or data center
+type Scope struct {
+	Cluster    string
+	DataCenter string
+}
+
+// IsZero returns if the scope does not restrict the collected objects
+func (s Scope) IsZero() bool {
+	return s.Cluster == "" && s.DataCenter == ""
+}
+
+// Search returns the search query for objects belonging to a cluster and data center (e.g. VMs and hosts)
+func (s Scope) Search() string {
+	terms := make([]string, 0, 2)
+
+	if s.Cluster != "" {
+		terms = append(terms, SearchTerm("cluster", s.Cluster))
+	}
+
+	if s.DataCenter != "" {
+		terms = append(terms, SearchTerm("datacenter", s.DataCenter))
+	}
+
+	return JoinSearch(terms...)
+}
+
+// DataCenterSearch returns the search query for objects belonging to a data center only (e.g. storage domains)
+func (s Scope) DataCenterSearch() string {
+	if s.DataCenter == "" {
+		return ""
+	}
+
+	return SearchTerm("datacenter", s.DataCenter)
+}
+
+// String returns a key unique for the scope
+func (s Scope) String() string {
+	return "cluster=" + s.Cluster + ",datacenter=" + s.DataCenter
+}
+
+// SearchTerm returns the search query matching objects with the attribute name set to value
+func SearchTerm(name, value string) string {
+	return name + `="` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
+
+// JoinSearch returns the search query matching objects matching all of the given queries (empty queries are ignored)
+func JoinSearch(queries ...string) string {
+	terms := make([]string, 0, len(queries))
+	for _, q := range queries {
+		if q != "" {
+			terms = append(terms, q)
+		}
+	}
+
+	return strings.Join(terms, " and ")
+}
+
+// ListPath returns the path listing the resources matching the search query (empty = all) following the given links
+func ListPath(resource, search string, follow []string) string {
+	params := make([]string, 0, 2)
+
+	if search != "" {
+		params = append(params, "search="+url.QueryEscape(search))
+	}
+
+	if len(follow) > 0 {
+		params = append(params, "follow="+strings.Join(follow, ","))
+	}
+
+	if len(params) == 0 {
+		return resource
+	}
+
+	return resource + "?" + strings.Join(params, "&")
+}
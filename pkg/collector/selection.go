@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: MIT
+
+package collector
+
+import (
+	"fmt"
+	"strings"
+)
+
+// collectorNames are the names of the collectors and sub collectors which can be selected (parent first)
+var collectorNames = []string{
+	"vm",
+	"vm_statistics",
+	"vm_network",
+	"vm_disks",
+	"vm_snapshots",
+	"host",
+	"host_statistics",
+	"host_network",
+	"storage",
+}
+
+// Selection defines the collectors and sub collectors enabled for a collection
+type Selection struct {
+	enabled map[string]bool
+}
+
+// SelectAll returns a selection enabling all collectors
+func SelectAll() Selection {
+	s := Selection{enabled: make(map[string]bool, len(collectorNames))}
+	for _, n := range collectorNames {
+		s.enabled[n] = true
+	}
+
+	return s
+}
+
+// ParseSelection returns the selection enabling the collectors in collect (all if empty) except the ones in exclude.
+// Selecting a sub collector (e.g. vm_disks) implies its parent collector (vm).
+func ParseSelection(collect, exclude []string) (Selection, error) {
+	s := SelectAll()
+
+	if len(collect) > 0 {
+		s.enabled = make(map[string]bool, len(collectorNames))
+	}
+
+	for _, n := range collect {
+		if !isCollectorName(n) {
+			return s, fmt.Errorf("unknown collector: %s", n)
+		}
+
+		s.enabled[n] = true
+		s.enabled[parent(n)] = true
+	}
+
+	for _, n := range exclude {
+		if !isCollectorName(n) {
+			return s, fmt.Errorf("unknown collector: %s", n)
+		}
+
+		s.enabled[n] = false
+	}
+
+	return s, nil
+}
+
+// Enabled returns if the collector is enabled. Sub collectors are disabled if their parent is disabled.
+func (s Selection) Enabled(name string) bool {
+	return s.enabled[name] && s.enabled[parent(name)]
+}
+
+// String returns a key unique for the selection
+func (s Selection) String() string {
+	names := make([]string, 0, len(collectorNames))
+	for _, n := range collectorNames {
+		if s.Enabled(n) {
+			names = append(names, n)
+		}
+	}
+
+	return strings.Join(names, ",")
+}
+
+func isCollectorName(name string) bool {
+	for _, n := range collectorNames {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parent returns the name of the collector a sub collector belongs to (the name itself for collectors)
+func parent(name string) string {
+	p, _, _ := strings.Cut(name, "_")
+	return p
+}
@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: MIT
+
+package collector
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// Shard selects the subset of objects collected by one of several exporter instances
+type Shard struct {
+	Index int
+	Count int
+}
+
+// ParseShard parses a shard definition in the form index/count (e.g. 0/3)
+func ParseShard(s string) (Shard, error) {
+	sh := Shard{}
+
+	index, count, found := strings.Cut(s, "/")
+	if !found {
+		return sh, fmt.Errorf("invalid shard %q (expected index/count)", s)
+	}
+
+	var err error
+	sh.Index, err = strconv.Atoi(index)
+	if err != nil {
+		return sh, fmt.Errorf("invalid shard %q (expected index/count): %w", s, err)
+	}
+
+	sh.Count, err = strconv.Atoi(count)
+	if err != nil {
+		return sh, fmt.Errorf("invalid shard %q (expected index/count): %w", s, err)
+	}
+
+	if sh.Count < 1 || sh.Index < 0 || sh.Index >= sh.Count {
+		return sh, fmt.Errorf("invalid shard %q: index has to be between 0 and count-1", s)
+	}
+
+	return sh, nil
+}
+
+// Includes returns if the object with the given ID belongs to the shard
+func (s Shard) Includes(id string) bool {
+	if s.Count <= 1 {
+		return true
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(id))
+
+	return jumpHash(h.Sum64(), s.Count) == s.Index
+}
+
+// IsFirst returns if this is the first shard (or sharding is not used)
+func (s Shard) IsFirst() bool {
+	return s.Index == 0
+}
+
+// jumpHash maps the key to one of the buckets using jump consistent hashing (Lamping, Veach 2014),
+// so only a minimal number of objects move to another shard when the number of shards changes
+func jumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+
+	return int(b)
+}
@@ -0,0 +1,169 @@
+// SPDX-License-Identifier: MIT
+
+package collector
+
+import (
+	"fmt"
+	"hash/fnv"
+	"testing"
+)
+
+func keys(n int) []uint64 {
+	res := make([]uint64, n)
+	for i := range res {
+		h := fnv.New64a()
+		fmt.Fprintf(h, "vm-%d", i)
+		res[i] = h.Sum64()
+	}
+
+	return res
+}
+
+func TestJumpHashDistribution(t *testing.T) {
+	tests := []struct {
+		name    string
+		buckets int
+	}{
+		{name: "single bucket", buckets: 1},
+		{name: "two buckets", buckets: 2},
+		{name: "three buckets", buckets: 3},
+		{name: "ten buckets", buckets: 10},
+		{name: "many buckets", buckets: 64},
+	}
+
+	ks := keys(100000)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			counts := make([]int, test.buckets)
+			for _, k := range ks {
+				b := jumpHash(k, test.buckets)
+				if b < 0 || b >= test.buckets {
+					t.Fatalf("bucket %d out of range [0, %d)", b, test.buckets)
+				}
+
+				counts[b]++
+			}
+
+			expected := float64(len(ks)) / float64(test.buckets)
+			for b, c := range counts {
+				if float64(c) < expected*0.9 || float64(c) > expected*1.1 {
+					t.Errorf("bucket %d got %d keys, expected %.0f (±10%%)", b, c, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestJumpHashStability(t *testing.T) {
+	tests := []struct {
+		name string
+		from int
+		to   int
+	}{
+		{name: "add one bucket", from: 2, to: 3},
+		{name: "add several buckets", from: 3, to: 8},
+		{name: "grow from single bucket", from: 1, to: 4},
+	}
+
+	ks := keys(10000)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			moved := 0
+			for _, k := range ks {
+				before := jumpHash(k, test.from)
+				after := jumpHash(k, test.to)
+
+				if before != jumpHash(k, test.from) {
+					t.Fatalf("key %d mapped to different buckets for the same number of buckets", k)
+				}
+
+				if before == after {
+					continue
+				}
+
+				if after < test.from {
+					t.Fatalf("key %d moved from bucket %d to existing bucket %d", k, before, after)
+				}
+				moved++
+			}
+
+			expected := float64(len(ks)) * float64(test.to-test.from) / float64(test.to)
+			if float64(moved) < expected*0.9 || float64(moved) > expected*1.1 {
+				t.Errorf("%d keys moved, expected %.0f (±10%%)", moved, expected)
+			}
+		})
+	}
+}
+
+func TestShardIncludes(t *testing.T) {
+	tests := []struct {
+		name  string
+		count int
+	}{
+		{name: "no sharding", count: 1},
+		{name: "three shards", count: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for i := 0; i < 1000; i++ {
+				id := fmt.Sprintf("vm-%d", i)
+
+				included := 0
+				for idx := 0; idx < test.count; idx++ {
+					if (Shard{Index: idx, Count: test.count}).Includes(id) {
+						included++
+					}
+				}
+
+				if included != 1 {
+					t.Fatalf("%s included in %d shards, expected exactly one", id, included)
+				}
+			}
+		})
+	}
+}
+
+func TestParseShard(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected Shard
+		valid    bool
+	}{
+		{name: "first shard", value: "0/3", expected: Shard{Index: 0, Count: 3}, valid: true},
+		{name: "last shard", value: "2/3", expected: Shard{Index: 2, Count: 3}, valid: true},
+		{name: "single shard", value: "0/1", expected: Shard{Index: 0, Count: 1}, valid: true},
+		{name: "trailing characters", value: "1/3x"},
+		{name: "additional part", value: "1/3/5"},
+		{name: "leading characters", value: "x1/3"},
+		{name: "whitespace", value: "1/ 3"},
+		{name: "missing count", value: "1/"},
+		{name: "missing separator", value: "13"},
+		{name: "empty", value: ""},
+		{name: "index out of range", value: "3/3"},
+		{name: "negative index", value: "-1/3"},
+		{name: "zero count", value: "0/0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sh, err := ParseShard(test.value)
+			if !test.valid {
+				if err == nil {
+					t.Errorf("expected %q to be rejected, got %+v", test.value, sh)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if sh != test.expected {
+				t.Errorf("got %+v, expected %+v", sh, test.expected)
+			}
+		})
+	}
+}
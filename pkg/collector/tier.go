@@ -0,0 +1,22 @@
+// SPDX-License-Identifier: MIT
+
+package collector
+
+// Tier defines which kind of API resources are queried by a collector
+type Tier int
+
+const (
+	// TierInventory covers rarely changing resources (e.g. vms, hosts, diskattachments, snapshots)
+	TierInventory Tier = 1 << iota
+
+	// TierStatistics covers frequently changing resources (statistics of VMs, hosts and NICs)
+	TierStatistics
+
+	// TierAll covers all resources
+	TierAll = TierInventory | TierStatistics
+)
+
+// Includes returns if the tier covers the given tier
+func (t Tier) Includes(o Tier) bool {
+	return t&o == o
+}
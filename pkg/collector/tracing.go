@@ -4,26 +4,56 @@ package collector
 
 import (
 	"context"
+	"time"
 
-	"github.com/czerwonk/ovirt_api/api"
+	"github.com/czerwonk/ovirt_exporter/pkg/namecache"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 )
 
 type clientTracingAdapter struct {
-	client *api.Client
-	tracer trace.Tracer
+	client      Client
+	tracer      trace.Tracer
+	limiter     *Limiter
+	rateLimiter *RateLimiter
+	breaker     *CircuitBreaker
+	retryPolicy RetryPolicy
 }
 
 // GetAndParse implements Client.GetAndParse
 func (cta *clientTracingAdapter) GetAndParse(ctx context.Context, path string, v interface{}) error {
-	_, span := cta.tracer.Start(ctx, "Client.RunCommandAndParseWithParser", trace.WithAttributes(
+	ctx, span := cta.tracer.Start(ctx, "Client.RunCommandAndParseWithParser", trace.WithAttributes(
 		attribute.String("path", path),
 	))
 	defer span.End()
 
-	err := cta.client.GetAndParse(path, v)
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = cta.breaker.Allow()
+		span.SetAttributes(attribute.String("circuit_breaker.state", cta.breaker.State().String()))
+		if err != nil {
+			break
+		}
+
+		err = cta.attempt(ctx, span, path, v)
+		cta.breaker.Record(err)
+
+		if !cta.retryPolicy.shouldRetry(attempt, err) {
+			break
+		}
+
+		span.AddEvent("retry", trace.WithAttributes(
+			attribute.Int("attempt", attempt+1),
+			attribute.String("error", err.Error()),
+		))
+
+		waitErr := cta.retryPolicy.wait(ctx, attempt)
+		if waitErr != nil {
+			break
+		}
+	}
+
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
@@ -31,3 +61,32 @@ func (cta *clientTracingAdapter) GetAndParse(ctx context.Context, path string, v
 
 	return err
 }
+
+// NameCaches implements namecache.Provider by returning the caches of the wrapped client
+func (cta *clientTracingAdapter) NameCaches() *namecache.Set {
+	if p, ok := cta.client.(namecache.Provider); ok {
+		return p.NameCaches()
+	}
+
+	return nil
+}
+
+func (cta *clientTracingAdapter) attempt(ctx context.Context, span trace.Span, path string, v interface{}) error {
+	err := cta.rateLimiter.Wait(ctx)
+	if err != nil {
+		return err
+	}
+
+	waited, err := cta.limiter.Acquire(ctx)
+	span.SetAttributes(attribute.Int64("wait_ms", waited.Milliseconds()))
+	if err != nil {
+		return err
+	}
+	defer cta.limiter.Release()
+
+	start := time.Now()
+	err = cta.client.GetAndParse(ctx, path, v)
+	cta.rateLimiter.Observe(time.Since(start), err)
+
+	return err
+}
@@ -0,0 +1,346 @@
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/czerwonk/ovirt_exporter/pkg/api"
+	"github.com/czerwonk/ovirt_exporter/pkg/filter"
+	"github.com/czerwonk/ovirt_exporter/pkg/metric"
+	"go.yaml.in/yaml/v2"
+)
+
+// DefaultModule is the module used for probes if no module is specified
+const DefaultModule = "default"
+
+// Config represents the configuration of the exporter
+type Config struct {
+	API        API               `yaml:"api"`
+	Collectors Collectors        `yaml:"collectors"`
+	Filters    Filters           `yaml:"filters"`
+	Metrics    Metrics           `yaml:"metrics"`
+	Tracing    Tracing           `yaml:"tracing"`
+	Engines    []Engine          `yaml:"engines"`
+	Modules    map[string]Module `yaml:"modules"`
+}
+
+// Module defines settings of API, collectors, filters and metrics replacing the global ones
+type Module struct {
+	API        yaml.MapSlice `yaml:"api"`
+	Collectors yaml.MapSlice `yaml:"collectors"`
+	Filters    yaml.MapSlice `yaml:"filters"`
+	Metrics    yaml.MapSlice `yaml:"metrics"`
+}
+
+// Engine defines an engine monitored by the exporter.
+// Settings of API, collectors, filters and metrics not defined for the engine are inherited from the global ones.
+type Engine struct {
+	Name   string `yaml:"name"`
+	Module `yaml:",inline"`
+}
+
+// Settings are the effective settings for a single engine
+type Settings struct {
+	Name       string
+	API        API
+	Collectors Collectors
+	Filters    Filters
+	Metrics    Metrics
+}
+
+// API defines the connection to the engine
+type API struct {
+	URL                       string        `yaml:"url"`
+	Username                  string        `yaml:"username"`
+	Password                  string        `yaml:"password"`
+	PasswordFile              string        `yaml:"password_file"`
+	PasswordFileCheckInterval time.Duration `yaml:"password_file_check_interval"`
+	AuthMethod                string        `yaml:"auth_method"`
+	InsecureCert              bool          `yaml:"insecure_cert"`
+	CAFile                    string        `yaml:"ca_file"`
+	CAFetch                   bool          `yaml:"ca_fetch"`
+	CAFingerprint             string        `yaml:"ca_fingerprint"`
+	ClientCertFile            string        `yaml:"client_cert_file"`
+	ClientKeyFile             string        `yaml:"client_key_file"`
+	TLSMinVersion             string        `yaml:"tls_min_version"`
+	ProxyURL                  string        `yaml:"proxy_url"`
+	Timeout                   time.Duration `yaml:"timeout"`
+	MaxIdleConnections        int           `yaml:"max_idle_connections"`
+	IdleConnectionTimeout     time.Duration `yaml:"idle_connection_timeout"`
+	MaxConcurrentRequests     int           `yaml:"max_concurrent_requests"`
+	RateLimit                 float64       `yaml:"rate_limit"`
+	RateLimitBurst            int           `yaml:"rate_limit_burst"`
+	RateLimitLatencyThreshold time.Duration `yaml:"rate_limit_latency_threshold"`
+	Retries                   int           `yaml:"retries"`
+	RetryBackoff              time.Duration `yaml:"retry_backoff"`
+	RetryMaxBackoff           time.Duration `yaml:"retry_max_backoff"`
+	CircuitBreakerThreshold   int           `yaml:"circuit_breaker_threshold"`
+	CircuitBreakerCooldown    time.Duration `yaml:"circuit_breaker_cooldown"`
+	Prefetch                  bool          `yaml:"prefetch"`
+	Follow                    bool          `yaml:"follow"`
+}
+
+// Collectors defines which optional metrics are collected
+type Collectors struct {
+	Snapshots bool `yaml:"snapshots"`
+	Network   bool `yaml:"network"`
+	Disks     bool `yaml:"disks"`
+}
+
+// Filters defines the VMs, hosts and storage domains collected. Hosts and storage domains can be filtered by name only.
+type Filters struct {
+	VMs            filter.Config `yaml:"vms"`
+	Hosts          filter.Config `yaml:"hosts"`
+	StorageDomains filter.Config `yaml:"storage_domains"`
+}
+
+// Metrics defines the metrics exported by the collectors by their names.
+// Names are matched by globs (e.g. ovirt_vm_network_*) or regular expressions enclosed in slashes.
+type Metrics struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
+
+// Tracing defines the export of traces using OpenTelemetry
+type Tracing struct {
+	Enabled           bool   `yaml:"enabled"`
+	Provider          string `yaml:"provider"`
+	CollectorEndpoint string `yaml:"collector_grpc_endpoint"`
+}
+
+// Load reads the YAML file at path into c. Settings missing in the file keep their current value.
+func Load(path string, c *Config) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config file: %w", err)
+	}
+
+	err = yaml.UnmarshalStrict(b, c)
+	if err != nil {
+		return fmt.Errorf("could not parse config file: %w", err)
+	}
+
+	return nil
+}
+
+// EngineSettings returns the effective settings of all engines.
+// Without engines defined the global settings are returned as a single unnamed engine (if the API URL is set).
+func (c *Config) EngineSettings() ([]Settings, error) {
+	if len(c.Engines) == 0 && c.API.URL == "" {
+		return nil, nil
+	}
+
+	if len(c.Engines) == 0 {
+		return []Settings{{API: c.API, Collectors: c.Collectors, Filters: c.Filters, Metrics: c.Metrics}}, nil
+	}
+
+	settings := make([]Settings, len(c.Engines))
+	for i, e := range c.Engines {
+		s, err := c.settings(e.Name, e.Module)
+		if err != nil {
+			return nil, fmt.Errorf("engine %s: %w", e.Name, err)
+		}
+
+		settings[i] = s
+	}
+
+	return settings, nil
+}
+
+// ModuleSettings returns the effective settings of a module used to probe the engine at target.
+// Only modules defined in the configuration can be used.
+func (c *Config) ModuleSettings(module, target string) (Settings, error) {
+	m, found := c.Modules[module]
+	if !found {
+		return Settings{}, fmt.Errorf("unknown module: %s", module)
+	}
+
+	s, err := c.settings("", m)
+	if err != nil {
+		return s, fmt.Errorf("module %s: %w", module, err)
+	}
+	s.API.URL = target
+
+	return s, nil
+}
+
+func (c *Config) settings(name string, m Module) (Settings, error) {
+	s := Settings{
+		Name:       name,
+		API:        c.API,
+		Collectors: c.Collectors,
+		Filters:    c.Filters,
+		Metrics:    c.Metrics,
+	}
+
+	err := override(&s.API, m.API)
+	if err != nil {
+		return s, err
+	}
+
+	err = override(&s.Collectors, m.Collectors)
+	if err != nil {
+		return s, err
+	}
+
+	err = override(&s.Filters, m.Filters)
+	if err != nil {
+		return s, err
+	}
+
+	err = override(&s.Metrics, m.Metrics)
+	if err != nil {
+		return s, err
+	}
+
+	return s, nil
+}
+
+// override replaces the settings in v defined in m
+func override(v interface{}, m yaml.MapSlice) error {
+	if len(m) == 0 {
+		return nil
+	}
+
+	b, err := yaml.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	return yaml.UnmarshalStrict(b, v)
+}
+
+// Validate checks the configuration for invalid settings
+func (c *Config) Validate() error {
+	names := make(map[string]bool)
+	for _, e := range c.Engines {
+		if e.Name == "" {
+			return fmt.Errorf("engine name must not be empty")
+		}
+
+		if names[e.Name] {
+			return fmt.Errorf("engine %s defined more than once", e.Name)
+		}
+		names[e.Name] = true
+	}
+
+	settings, err := c.EngineSettings()
+	if err != nil {
+		return err
+	}
+
+	for _, s := range settings {
+		err := s.API.validate()
+		if err == nil {
+			err = s.Filters.validate()
+		}
+
+		if err == nil {
+			err = s.Metrics.validate()
+		}
+
+		if err == nil {
+			continue
+		}
+
+		if s.Name != "" {
+			return fmt.Errorf("engine %s: %w", s.Name, err)
+		}
+
+		return err
+	}
+
+	for name, m := range c.Modules {
+		s, err := c.settings(name, m)
+		if err == nil {
+			err = s.API.validateConnection()
+		}
+
+		if err == nil {
+			err = s.Filters.validate()
+		}
+
+		if err == nil {
+			err = s.Metrics.validate()
+		}
+
+		if err != nil {
+			return fmt.Errorf("module %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (a *API) validate() error {
+	if a.URL == "" {
+		return fmt.Errorf("api.url must not be empty")
+	}
+
+	_, err := url.Parse(a.URL)
+	if err != nil {
+		return fmt.Errorf("invalid api.url: %w", err)
+	}
+
+	return a.validateConnection()
+}
+
+// validateConnection checks the settings of the API connection except the URL
+func (a *API) validateConnection() error {
+	if a.ProxyURL != "" {
+		_, err := url.Parse(a.ProxyURL)
+		if err != nil {
+			return fmt.Errorf("invalid api.proxy_url: %w", err)
+		}
+	}
+
+	_, err := api.ParseAuthMethod(a.AuthMethod)
+	if err != nil {
+		return err
+	}
+
+	_, err = api.ParseTLSVersion(a.TLSMinVersion)
+	if err != nil {
+		return err
+	}
+
+	if a.ClientCertFile != "" && a.ClientKeyFile == "" {
+		return fmt.Errorf("api.client_key_file must be set if api.client_cert_file is set")
+	}
+
+	if a.Timeout < 0 || a.IdleConnectionTimeout < 0 || a.RetryBackoff < 0 || a.RetryMaxBackoff < 0 || a.CircuitBreakerCooldown < 0 {
+		return fmt.Errorf("durations must not be negative")
+	}
+
+	if a.MaxConcurrentRequests < 0 || a.RateLimit < 0 || a.Retries < 0 || a.CircuitBreakerThreshold < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+
+	return nil
+}
+
+func (f *Filters) validate() error {
+	for _, r := range []filter.Rules{f.Hosts.Include, f.Hosts.Exclude, f.StorageDomains.Include, f.StorageDomains.Exclude} {
+		if len(r.Clusters) > 0 || len(r.Hosts) > 0 || len(r.Tags) > 0 || len(r.Statuses) > 0 {
+			return fmt.Errorf("hosts and storage domains can only be filtered by name")
+		}
+	}
+
+	for _, cfg := range []filter.Config{f.VMs, f.Hosts, f.StorageDomains} {
+		_, err := filter.New(cfg)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *Metrics) validate() error {
+	_, err := metric.NewFilter(m.Allow, m.Deny)
+	return err
+}
@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: MIT
+
+package credentials
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// EnvPassword is the environment variable the password is read from if neither a password file nor a password is set
+const EnvPassword = "OVIRT_EXPORTER_API_PASSWORD"
+
+var lastReloadDesc = prometheus.NewDesc("ovirt_exporter_api_credentials_last_reload_timestamp_seconds", "Timestamp of the last time changed API credentials were loaded", nil, nil)
+
+// Source provides the API password from a file, a fixed value or the environment (in this order)
+type Source struct {
+	file       string
+	fallback   string
+	mutex      sync.Mutex
+	password   string
+	lastReload time.Time
+}
+
+// NewSource creates a new source and loads the password
+func NewSource(file, fallback string) (*Source, error) {
+	s := &Source{
+		file:     file,
+		fallback: fallback,
+	}
+
+	if _, found := os.LookupEnv(EnvPassword); found && (file != "" || fallback != "") {
+		log.Warnf("environment variable %s is ignored, the password is set explicitly", EnvPassword)
+	}
+
+	_, err := s.Load(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Source) read() (string, error) {
+	if s.file != "" {
+		b, err := os.ReadFile(s.file)
+		if err != nil {
+			return "", fmt.Errorf("could not read password file: %w", err)
+		}
+
+		return strings.Trim(string(b), "\n"), nil
+	}
+
+	if s.fallback != "" {
+		return s.fallback, nil
+	}
+
+	return os.Getenv(EnvPassword), nil
+}
+
+// Load reads the password again and returns it
+func (s *Source) Load(ctx context.Context) (string, error) {
+	p, _, err := s.reload()
+	return p, err
+}
+
+func (s *Source) reload() (password string, changed bool, err error) {
+	p, err := s.read()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err != nil {
+		return s.password, false, err
+	}
+
+	if p == s.password && !s.lastReload.IsZero() {
+		return p, false, nil
+	}
+
+	if !s.lastReload.IsZero() {
+		log.Infof("API password changed, reloaded credentials")
+	}
+
+	s.password = p
+	s.lastReload = time.Now()
+
+	return p, true, nil
+}
+
+// Watch checks the password file for changes in the given interval and calls onChange for every change
+func (s *Source) Watch(ctx context.Context, interval time.Duration, onChange func(ctx context.Context)) {
+	if s.file == "" || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		_, changed, err := s.reload()
+		if err != nil {
+			log.Errorf("could not reload API password: %v", err)
+			continue
+		}
+
+		if changed {
+			onChange(ctx)
+		}
+	}
+}
+
+// Describe implements Prometheus Collector interface
+func (s *Source) Describe(ch chan<- *prometheus.Desc) {
+	ch <- lastReloadDesc
+}
+
+// Collect implements Prometheus Collector interface
+func (s *Source) Collect(ch chan<- prometheus.Metric) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(lastReloadDesc, prometheus.GaugeValue, float64(s.lastReload.UnixNano())/1e9)
+}
@@ -0,0 +1,9 @@
+// SPDX-License-Identifier: MIT
+
+package datacenter
+
+// DataCenter represents the data center resource
+type DataCenter struct {
+	ID   string `xml:"id,attr"`
+	Name string `xml:"name"`
+}
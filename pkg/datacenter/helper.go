@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: MIT
+
+package datacenter
+
+import (
+	"context"
+
+	"fmt"
+
+	"github.com/czerwonk/ovirt_exporter/pkg/collector"
+	"github.com/czerwonk/ovirt_exporter/pkg/namecache"
+	log "github.com/sirupsen/logrus"
+)
+
+// Get retrieves data center information
+func Get(ctx context.Context, id string, cl collector.Client) (*DataCenter, error) {
+	path := fmt.Sprintf("datacenters/%s", id)
+
+	d := DataCenter{}
+	err := cl.GetAndParse(ctx, path, &d)
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// Name retrieves data center name
+func Name(ctx context.Context, id string, cl collector.Client) string {
+	if id == "" {
+		return ""
+	}
+
+	n, _ := namecache.For(cl, "data_center").Get(ctx, id, func(ctx context.Context, id string) (string, error) {
+		d, err := Get(ctx, id, cl)
+		if err != nil {
+			log.Error(err)
+			return "", err
+		}
+
+		return d.Name, nil
+	})
+
+	return n
+}
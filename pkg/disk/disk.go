@@ -4,6 +4,11 @@ package disk
 
 import "github.com/czerwonk/ovirt_exporter/pkg/storagedomain"
 
+// Disks is a collection of disks
+type Disks struct {
+	Disks []Disk `xml:"disk"`
+}
+
 // Disk represents the disk resource
 type Disk struct {
 	ID              string                        `xml:"id,attr"`
@@ -17,9 +22,18 @@ type Disk struct {
 
 // StorageDomainName returns the name of the storage domain of the disk
 func (d *Disk) StorageDomainName() string {
-	if len(d.StorageDomains.Domains) == 0 {
+	if d.StorageDomains == nil || len(d.StorageDomains.Domains) == 0 {
 		return ""
 	}
 
 	return d.StorageDomains.Domains[0].Name
 }
+
+// StorageDomainID returns the ID of the storage domain of the disk
+func (d *Disk) StorageDomainID() string {
+	if d.StorageDomains == nil || len(d.StorageDomains.Domains) == 0 {
+		return ""
+	}
+
+	return d.StorageDomains.Domains[0].ID
+}
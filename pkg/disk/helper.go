@@ -20,12 +20,41 @@ func Get(ctx context.Context, id string, cl collector.Client) (*Disk, error) {
 		return nil, err
 	}
 
+	ResolveStorageDomains(ctx, d, nil, cl)
+
+	return d, nil
+}
+
+// List retrieves all disks. Storage domain names are resolved using domainNames (ID to name) if known.
+func List(ctx context.Context, domainNames map[string]string, cl collector.Client) ([]Disk, error) {
+	d := Disks{}
+	err := cl.GetAndParse(ctx, "disks", &d)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range d.Disks {
+		ResolveStorageDomains(ctx, &d.Disks[i], domainNames, cl)
+	}
+
+	return d.Disks, nil
+}
+
+// ResolveStorageDomains sets the names of the storage domains of the disk. Names are resolved using domainNames (ID to name) if known.
+func ResolveStorageDomains(ctx context.Context, d *Disk, domainNames map[string]string, cl collector.Client) {
+	if d.StorageDomains == nil {
+		return
+	}
+
 	for i, dom := range d.StorageDomains.Domains {
+		name, found := domainNames[dom.ID]
+		if !found {
+			name = storagedomain.Name(ctx, dom.ID, cl)
+		}
+
 		d.StorageDomains.Domains[i] = storagedomain.StorageDomain{
 			ID:   dom.ID,
-			Name: storagedomain.Name(ctx, dom.ID, cl),
+			Name: name,
 		}
 	}
-
-	return d, nil
 }
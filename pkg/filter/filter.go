@@ -0,0 +1,269 @@
+// SPDX-License-Identifier: MIT
+
+package filter
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/czerwonk/ovirt_exporter/pkg/collector"
+)
+
+// Rules defines criteria objects are matched against.
+// Names are matched by regular expressions (anchored), all other attributes by their exact value.
+type Rules struct {
+	Names    []string `yaml:"names"`
+	Clusters []string `yaml:"clusters"`
+	Hosts    []string `yaml:"hosts"`
+	Tags     []string `yaml:"tags"`
+	Statuses []string `yaml:"statuses"`
+}
+
+// IsEmpty returns if no criteria are defined
+func (r Rules) IsEmpty() bool {
+	return len(r.Names) == 0 && len(r.Clusters) == 0 && len(r.Hosts) == 0 && len(r.Tags) == 0 && len(r.Statuses) == 0
+}
+
+// Config defines the objects included in and excluded from the collection.
+// An object is included if it matches all criteria of Include (one value per criterion) and none of Exclude.
+type Config struct {
+	Include Rules `yaml:"include"`
+	Exclude Rules `yaml:"exclude"`
+}
+
+// Object provides the attributes of an object a filter is applied to.
+// Cluster, Host and Tags are only called if the filter defines criteria for them, so resolving them can be deferred.
+type Object struct {
+	Name    string
+	Status  string
+	Cluster func() string
+	Host    func() string
+	Tags    func() []string
+}
+
+// Filter decides which objects are collected
+type Filter struct {
+	include    *rules
+	exclude    *rules
+	search     string
+	searchable bool
+}
+
+type rules struct {
+	names    []*regexp.Regexp
+	clusters map[string]bool
+	hosts    map[string]bool
+	tags     map[string]bool
+	statuses map[string]bool
+}
+
+// New creates a filter from the config. A filter without criteria includes all objects.
+func New(cfg Config) (*Filter, error) {
+	include, err := compile(cfg.Include)
+	if err != nil {
+		return nil, err
+	}
+
+	exclude, err := compile(cfg.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	search, searchable := searchQuery(cfg)
+
+	return &Filter{
+		include:    include,
+		exclude:    exclude,
+		search:     search,
+		searchable: searchable,
+	}, nil
+}
+
+// searchQuery returns the search query for the include criteria having a single value.
+// Names (regular expressions) and statuses (named differently by the search syntax) are not supported by the engine.
+// The query selects exactly the objects matched by the filter if no other criteria are defined.
+func searchQuery(cfg Config) (string, bool) {
+	searchable := cfg.Exclude.IsEmpty() && len(cfg.Include.Names) == 0 && len(cfg.Include.Statuses) == 0
+	terms := make([]string, 0, 3)
+
+	for _, c := range []struct {
+		name   string
+		values []string
+	}{
+		{name: "cluster", values: cfg.Include.Clusters},
+		{name: "host", values: cfg.Include.Hosts},
+		{name: "tag", values: cfg.Include.Tags},
+	} {
+		if len(c.values) == 1 {
+			terms = append(terms, collector.SearchTerm(c.name, c.values[0]))
+		} else if len(c.values) > 1 {
+			searchable = false
+		}
+	}
+
+	return collector.JoinSearch(terms...), searchable
+}
+
+func compile(r Rules) (*rules, error) {
+	if r.IsEmpty() {
+		return nil, nil
+	}
+
+	c := &rules{
+		names:    make([]*regexp.Regexp, len(r.Names)),
+		clusters: set(r.Clusters),
+		hosts:    set(r.Hosts),
+		tags:     set(r.Tags),
+		statuses: set(r.Statuses),
+	}
+
+	for i, n := range r.Names {
+		re, err := regexp.Compile("^(?:" + n + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid name filter %q: %w", n, err)
+		}
+
+		c.names[i] = re
+	}
+
+	return c, nil
+}
+
+func set(values []string) map[string]bool {
+	m := make(map[string]bool, len(values))
+	for _, v := range values {
+		m[v] = true
+	}
+
+	return m
+}
+
+// IsEmpty returns if the filter includes all objects
+func (f *Filter) IsEmpty() bool {
+	return f == nil || (f.include == nil && f.exclude == nil)
+}
+
+// Search returns the search query restricting the objects requested from the engine to the ones possibly matching the filter (empty = all)
+func (f *Filter) Search() string {
+	if f == nil {
+		return ""
+	}
+
+	return f.search
+}
+
+// Searchable returns if the objects returned by Search match the filter without further checks
+func (f *Filter) Searchable() bool {
+	return f.IsEmpty() || f.searchable
+}
+
+// Tags returns the tags used by the filter
+func (f *Filter) Tags() []string {
+	if f == nil {
+		return nil
+	}
+
+	tags := make([]string, 0)
+	for _, r := range []*rules{f.include, f.exclude} {
+		if r == nil {
+			continue
+		}
+
+		for t := range r.tags {
+			tags = append(tags, t)
+		}
+	}
+
+	return tags
+}
+
+// Matches returns if the object is collected
+func (f *Filter) Matches(o Object) bool {
+	if f.IsEmpty() {
+		return true
+	}
+
+	if f.include != nil && !f.include.matchesAll(o) {
+		return false
+	}
+
+	if f.exclude != nil && f.exclude.matchesAny(o) {
+		return false
+	}
+
+	return true
+}
+
+// matchesAll returns if the object matches every criterion defined
+func (r *rules) matchesAll(o Object) bool {
+	if len(r.names) > 0 && !matchesName(r.names, o.Name) {
+		return false
+	}
+
+	if len(r.statuses) > 0 && !r.statuses[o.Status] {
+		return false
+	}
+
+	if len(r.clusters) > 0 && !r.clusters[value(o.Cluster)] {
+		return false
+	}
+
+	if len(r.hosts) > 0 && !r.hosts[value(o.Host)] {
+		return false
+	}
+
+	if len(r.tags) > 0 && !containsAny(r.tags, o.Tags) {
+		return false
+	}
+
+	return true
+}
+
+// matchesAny returns if the object matches at least one criterion defined
+func (r *rules) matchesAny(o Object) bool {
+	if matchesName(r.names, o.Name) || r.statuses[o.Status] {
+		return true
+	}
+
+	if len(r.clusters) > 0 && r.clusters[value(o.Cluster)] {
+		return true
+	}
+
+	if len(r.hosts) > 0 && r.hosts[value(o.Host)] {
+		return true
+	}
+
+	return len(r.tags) > 0 && containsAny(r.tags, o.Tags)
+}
+
+func matchesName(names []*regexp.Regexp, name string) bool {
+	for _, re := range names {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func value(f func() string) string {
+	if f == nil {
+		return ""
+	}
+
+	return f()
+}
+
+func containsAny(m map[string]bool, f func() []string) bool {
+	if f == nil {
+		return false
+	}
+
+	for _, v := range f() {
+		if m[v] {
+			return true
+		}
+	}
+
+	return false
+}
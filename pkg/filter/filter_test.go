@@ -0,0 +1,188 @@
+// SPDX-License-Identifier: MIT
+
+package filter
+
+import "testing"
+
+func object(name, status, cluster, host string, tags ...string) Object {
+	return Object{
+		Name:    name,
+		Status:  status,
+		Cluster: func() string { return cluster },
+		Host:    func() string { return host },
+		Tags:    func() []string { return tags },
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      Config
+		object   Object
+		expected bool
+	}{
+		{
+			name:     "empty filter",
+			object:   object("vm1", "up", "c1", "h1"),
+			expected: true,
+		},
+		{
+			name:     "include name",
+			cfg:      Config{Include: Rules{Names: []string{"web-.*"}}},
+			object:   object("web-1", "up", "c1", "h1"),
+			expected: true,
+		},
+		{
+			name:     "include name is anchored",
+			cfg:      Config{Include: Rules{Names: []string{"web"}}},
+			object:   object("my-web-1", "up", "c1", "h1"),
+			expected: false,
+		},
+		{
+			name:     "include any value of a criterion",
+			cfg:      Config{Include: Rules{Clusters: []string{"c1", "c2"}}},
+			object:   object("vm1", "up", "c2", "h1"),
+			expected: true,
+		},
+		{
+			name:     "include requires all criteria",
+			cfg:      Config{Include: Rules{Clusters: []string{"c1"}, Statuses: []string{"up"}}},
+			object:   object("vm1", "down", "c1", "h1"),
+			expected: false,
+		},
+		{
+			name:     "include all criteria matching",
+			cfg:      Config{Include: Rules{Clusters: []string{"c1"}, Hosts: []string{"h1"}, Tags: []string{"prod"}}},
+			object:   object("vm1", "up", "c1", "h1", "prod", "web"),
+			expected: true,
+		},
+		{
+			name:     "include tag missing",
+			cfg:      Config{Include: Rules{Tags: []string{"prod"}}},
+			object:   object("vm1", "up", "c1", "h1", "web"),
+			expected: false,
+		},
+		{
+			name:     "exclude any criterion",
+			cfg:      Config{Exclude: Rules{Names: []string{"ci-.*"}, Statuses: []string{"down"}}},
+			object:   object("vm1", "down", "c1", "h1"),
+			expected: false,
+		},
+		{
+			name:     "exclude not matching",
+			cfg:      Config{Exclude: Rules{Names: []string{"ci-.*"}, Tags: []string{"throwaway"}}},
+			object:   object("vm1", "up", "c1", "h1", "prod"),
+			expected: true,
+		},
+		{
+			name:     "exclude wins over include",
+			cfg:      Config{Include: Rules{Clusters: []string{"c1"}}, Exclude: Rules{Hosts: []string{"h1"}}},
+			object:   object("vm1", "up", "c1", "h1"),
+			expected: false,
+		},
+		{
+			name:     "unresolved attributes",
+			cfg:      Config{Include: Rules{Clusters: []string{"c1"}}},
+			object:   Object{Name: "vm1"},
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := New(test.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := f.Matches(test.object); got != test.expected {
+				t.Errorf("got %v, expected %v", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestMatchesResolvesAttributesOnDemand(t *testing.T) {
+	f, err := New(Config{Include: Rules{Names: []string{"vm1"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := Object{
+		Name:    "vm1",
+		Cluster: func() string { t.Error("cluster resolved"); return "" },
+		Host:    func() string { t.Error("host resolved"); return "" },
+		Tags:    func() []string { t.Error("tags resolved"); return nil },
+	}
+
+	if !f.Matches(o) {
+		t.Error("expected object to match")
+	}
+}
+
+func TestSearch(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        Config
+		search     string
+		searchable bool
+	}{
+		{
+			name:       "empty filter",
+			searchable: true,
+		},
+		{
+			name:       "single values",
+			cfg:        Config{Include: Rules{Clusters: []string{"c1"}, Hosts: []string{"h1"}, Tags: []string{"prod"}}},
+			search:     `cluster="c1" and host="h1" and tag="prod"`,
+			searchable: true,
+		},
+		{
+			name:       "multiple values",
+			cfg:        Config{Include: Rules{Clusters: []string{"c1", "c2"}, Tags: []string{"prod"}}},
+			search:     `tag="prod"`,
+			searchable: false,
+		},
+		{
+			name:       "names",
+			cfg:        Config{Include: Rules{Names: []string{"web-.*"}, Clusters: []string{"c1"}}},
+			search:     `cluster="c1"`,
+			searchable: false,
+		},
+		{
+			name:       "statuses",
+			cfg:        Config{Include: Rules{Statuses: []string{"up"}}},
+			searchable: false,
+		},
+		{
+			name:       "exclude",
+			cfg:        Config{Include: Rules{Clusters: []string{"c1"}}, Exclude: Rules{Tags: []string{"throwaway"}}},
+			search:     `cluster="c1"`,
+			searchable: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := New(test.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := f.Search(); got != test.search {
+				t.Errorf("got search %q, expected %q", got, test.search)
+			}
+
+			if got := f.Searchable(); got != test.searchable {
+				t.Errorf("got searchable %v, expected %v", got, test.searchable)
+			}
+		})
+	}
+}
+
+func TestNewInvalidName(t *testing.T) {
+	_, err := New(Config{Exclude: Rules{Names: []string{"("}}})
+	if err == nil {
+		t.Error("expected error for invalid regular expression")
+	}
+}
@@ -4,19 +4,14 @@ package host
 
 import (
 	"context"
-	"sync"
 
 	"fmt"
 
 	"github.com/czerwonk/ovirt_exporter/pkg/collector"
+	"github.com/czerwonk/ovirt_exporter/pkg/namecache"
 	log "github.com/sirupsen/logrus"
 )
 
-var (
-	cacheMutex = sync.Mutex{}
-	nameCache  = make(map[string]string)
-)
-
 // Get retrieves host information
 func Get(ctx context.Context, id string, cl collector.Client) (*Host, error) {
 	path := fmt.Sprintf("hosts/%s", id)
@@ -32,19 +27,42 @@ func Get(ctx context.Context, id string, cl collector.Client) (*Host, error) {
 
 // Name retrieves host name
 func Name(ctx context.Context, id string, cl collector.Client) string {
-	cacheMutex.Lock()
-	defer cacheMutex.Unlock()
+	n, _ := namecache.For(cl, "host").Get(ctx, id, func(ctx context.Context, id string) (string, error) {
+		h, err := Get(ctx, id, cl)
+		if err != nil {
+			log.Error(err)
+			return "", err
+		}
+
+		return h.Name, nil
+	})
+
+	return n
+}
 
-	if n, found := nameCache[id]; found {
-		return n
+// UpdateNames updates the cached names with hosts retrieved by a list call
+func UpdateNames(hosts []Host, cl collector.Client) {
+	names := make(map[string]string, len(hosts))
+	for _, h := range hosts {
+		names[h.ID] = h.Name
 	}
 
-	h, err := Get(ctx, id, cl)
+	namecache.For(cl, "host").Update(names)
+}
+
+// List retrieves all hosts
+func List(ctx context.Context, cl collector.Client) ([]Host, error) {
+	return Search(ctx, "", nil, cl)
+}
+
+// Search retrieves the hosts matching the search query (empty = all) with the given links (e.g. statistics) embedded
+func Search(ctx context.Context, query string, links []string, cl collector.Client) ([]Host, error) {
+	h := Hosts{}
+	err := cl.GetAndParse(ctx, collector.ListPath("hosts", query, links), &h)
 	if err != nil {
-		log.Error(err)
-		return ""
+		return nil, err
 	}
 
-	nameCache[id] = h.Name
-	return h.Name
+	UpdateNames(h.Hosts, cl)
+	return h.Hosts, nil
 }
@@ -2,6 +2,31 @@
 
 package host
 
+import (
+	"github.com/czerwonk/ovirt_exporter/pkg/network"
+	"github.com/czerwonk/ovirt_exporter/pkg/statistic"
+)
+
+// Statuses are the states of a host defined by the API
+var Statuses = []string{
+	"connecting",
+	"down",
+	"error",
+	"initializing",
+	"install_failed",
+	"installing",
+	"installing_os",
+	"kdumping",
+	"maintenance",
+	"non_operational",
+	"non_responsive",
+	"pending_approval",
+	"preparing_for_maintenance",
+	"reboot",
+	"unassigned",
+	"up",
+}
+
 // Hosts is a collection of Host
 type Hosts struct {
 	Hosts []Host `xml:"host"`
@@ -24,4 +49,8 @@ type Host struct {
 		} `xml:"topology"`
 	} `xml:"cpu"`
 	Memory int64 `xml:"memory"`
+
+	// linked collections, only present if retrieved by following links
+	Statistics *statistic.Statistics `xml:"statistics,omitempty"`
+	Nics       *network.HostNics     `xml:"nics,omitempty"`
 }
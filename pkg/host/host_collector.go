@@ -10,10 +10,12 @@ import (
 
 	"github.com/czerwonk/ovirt_exporter/pkg/cluster"
 	"github.com/czerwonk/ovirt_exporter/pkg/collector"
+	"github.com/czerwonk/ovirt_exporter/pkg/filter"
 	"github.com/czerwonk/ovirt_exporter/pkg/metric"
 	"github.com/czerwonk/ovirt_exporter/pkg/network"
 	"github.com/czerwonk/ovirt_exporter/pkg/statistic"
 	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -27,19 +29,52 @@ var (
 	cpuThreadsDesc       *prometheus.Desc
 	cpuSpeedDesc         *prometheus.Desc
 	memoryDesc           *prometheus.Desc
+	infoDesc             *prometheus.Desc
+	statusDesc           *prometheus.Desc
 	labelNames           []string
-	hostMaintenanceRegex *regexp.Regexp
+	hostMaintenanceRegex = regexp.MustCompile(`maintenance|installing`)
+	descsOnce            sync.Once
 )
 
-func init() {
+// initDescs creates the descriptors according to the label mode (once before the first collection)
+func initDescs() {
 	labelNames = []string{"name", "cluster"}
-	upDesc = prometheus.NewDesc(prefix+"up", "Host status is up (1) or not (0) or on maintenance (2)", labelNames, nil)
-	cpuCoresDesc = prometheus.NewDesc(prefix+"cpu_cores", "Number of CPU cores assigned", labelNames, nil)
-	cpuSocketsDesc = prometheus.NewDesc(prefix+"cpu_sockets", "Number of sockets", labelNames, nil)
-	cpuThreadsDesc = prometheus.NewDesc(prefix+"cpu_threads", "Number of threads", labelNames, nil)
-	cpuSpeedDesc = prometheus.NewDesc(prefix+"cpu_speed_hertz", "CPU speed in hertz", labelNames, nil)
-	memoryDesc = prometheus.NewDesc(prefix+"memory_installed_bytes", "Memory installed in bytes", labelNames, nil)
-	hostMaintenanceRegex = regexp.MustCompile(`maintenance|installing`)
+	if metric.IDLabels() {
+		labelNames = []string{"host_id"}
+	}
+
+	infoDesc = metric.NewDesc(prefix+"info", "Names of the host and the objects it belongs to", []string{"host_id", "name", "cluster", "datacenter"}, nil)
+	upDesc = metric.NewDesc(prefix+"up", "Host status is up (1) or not (0) or on maintenance (2)", labelNames, nil)
+	statusDesc = metric.NewDesc(prefix+"status", "Status of the host (1 for the current status, 0 for all others)", append(labelNames, "status"), nil)
+	cpuCoresDesc = metric.NewDesc(prefix+"cpu_cores", "Number of CPU cores assigned", labelNames, nil)
+	cpuSocketsDesc = metric.NewDesc(prefix+"cpu_sockets", "Number of sockets", labelNames, nil)
+	cpuThreadsDesc = metric.NewDesc(prefix+"cpu_threads", "Number of threads", labelNames, nil)
+	cpuSpeedDesc = metric.NewDesc(prefix+"cpu_speed_hertz", "CPU speed in hertz", labelNames, nil)
+	memoryDesc = metric.NewDesc(prefix+"memory_installed_bytes", "Memory installed in bytes", labelNames, nil)
+}
+
+// Config defines which metrics are retrieved by the collector
+type Config struct {
+	// Tier defines which kind of resources are queried
+	Tier collector.Tier
+
+	// CollectStatistics enables host statistics (statistics)
+	CollectStatistics bool
+
+	// CollectNetwork enables NIC metrics (statistics)
+	CollectNetwork bool
+
+	// Prefetch retrieves referenced clusters as list once per collection
+	Prefetch bool
+
+	// Follow retrieves statistics and NICs embedded in the host list
+	Follow bool
+
+	// Search restricts the collected hosts to the ones matching the search query (empty = all)
+	Search string
+
+	// Filter restricts the collected hosts by name (nil = all)
+	Filter *filter.Filter
 }
 
 // HostCollector collects host statistics from oVirt
@@ -47,17 +82,20 @@ type HostCollector struct {
 	collectDuration prometheus.Observer
 	cc              *collector.CollectorContext
 	metrics         []prometheus.Metric
-	collectNetwork  bool
+	cfg             Config
+	clusters        map[string]string
 	mutex           sync.Mutex
 	rootCtx         context.Context
 }
 
 // NewCollector creates a new collector
-func NewCollector(ctx context.Context, cc *collector.CollectorContext, collectNetwork bool, collectDuration prometheus.Observer) prometheus.Collector {
+func NewCollector(ctx context.Context, cc *collector.CollectorContext, cfg Config, collectDuration prometheus.Observer) prometheus.Collector {
+	descsOnce.Do(initDescs)
+
 	return &HostCollector{
 		rootCtx:         ctx,
 		cc:              cc,
-		collectNetwork:  collectNetwork,
+		cfg:             cfg,
 		collectDuration: collectDuration}
 }
 
@@ -97,19 +135,23 @@ func (c *HostCollector) retrieveMetrics(ctx context.Context, span trace.Span) {
 	timer := prometheus.NewTimer(c.collectDuration)
 	defer timer.ObserveDuration()
 
-	h := Hosts{}
-	err := c.cc.Client().GetAndParse(ctx, "hosts", &h)
+	hosts, err := c.retrieveHosts(ctx)
 	if err != nil {
 		c.cc.HandleError(err, span)
 		return
 	}
+	hosts = c.filter(hosts)
+
+	if c.cfg.Prefetch {
+		c.prefetchClusters(ctx)
+	}
 
 	wg := &sync.WaitGroup{}
-	wg.Add(len(h.Hosts))
+	wg.Add(len(hosts))
 
 	ch := make(chan prometheus.Metric)
 	c.cc.SetMetricsCh(ch)
-	for _, h := range h.Hosts {
+	for _, h := range hosts {
 		go c.collectForHost(ctx, h, wg)
 	}
 
@@ -123,6 +165,46 @@ func (c *HostCollector) retrieveMetrics(ctx context.Context, span trace.Span) {
 	}
 }
 
+func (c *HostCollector) retrieveHosts(ctx context.Context) ([]Host, error) {
+	search := collector.JoinSearch(c.cfg.Search, c.cfg.Filter.Search())
+
+	// hosts not matching the filter are dropped after listing, so their sub resources are requested individually for the remaining ones
+	if c.cfg.Follow && c.cfg.Tier.Includes(collector.TierStatistics) && c.cfg.Filter.Searchable() {
+		links := make([]string, 0, 2)
+		if c.cfg.CollectStatistics {
+			links = append(links, "statistics")
+		}
+
+		if c.cfg.CollectNetwork {
+			links = append(links, "nics.statistics")
+		}
+
+		hosts, err := Search(ctx, search, links, c.cc.Client())
+		if err == nil {
+			return hosts, nil
+		}
+
+		log.Warnf("could not retrieve hosts following links (%v), falling back to individual requests", err)
+	}
+
+	return Search(ctx, search, nil, c.cc.Client())
+}
+
+func (c *HostCollector) filter(hosts []Host) []Host {
+	if c.cfg.Filter.Searchable() {
+		return hosts
+	}
+
+	res := make([]Host, 0, len(hosts))
+	for _, h := range hosts {
+		if c.cfg.Filter.Matches(filter.Object{Name: h.Name}) {
+			res = append(res, h)
+		}
+	}
+
+	return res
+}
+
 func (c *HostCollector) collectForHost(ctx context.Context, host Host, wg *sync.WaitGroup) {
 	ctx, span := c.cc.Tracer().Start(ctx, "HostCollector.CollectForHost", trace.WithAttributes(
 		attribute.String("host_name", host.Name),
@@ -133,23 +215,91 @@ func (c *HostCollector) collectForHost(ctx context.Context, host Host, wg *sync.
 	defer wg.Done()
 
 	h := &host
-	l := []string{h.Name, cluster.Name(ctx, h.Cluster.ID, c.cc.Client())}
+	l := c.labelValues(ctx, h)
+
+	if c.cfg.Tier.Includes(collector.TierInventory) {
+		if metric.IDLabels() {
+			c.cc.RecordMetrics(c.infoMetric(ctx, h))
+		}
+
+		c.cc.RecordMetrics(
+			c.upMetric(h, l),
+			metric.MustCreate(memoryDesc, float64(host.Memory), l),
+		)
+		c.cc.RecordMetrics(metric.StateSet(statusDesc, Statuses, h.Status, l)...)
+		c.collectCPUMetrics(h, l)
+	}
 
-	c.cc.RecordMetrics(
-		c.upMetric(h, l),
-		metric.MustCreate(memoryDesc, float64(host.Memory), l),
-	)
-	c.collectCPUMetrics(h, l)
+	if c.cfg.Tier.Includes(collector.TierStatistics) {
+		c.collectStatisticMetrics(ctx, h, l)
+	}
+}
+
+func (c *HostCollector) labelValues(ctx context.Context, host *Host) []string {
+	if metric.IDLabels() {
+		return []string{host.ID}
+	}
+
+	return []string{host.Name, c.clusterName(ctx, host.Cluster.ID)}
+}
+
+func (c *HostCollector) infoMetric(ctx context.Context, host *Host) prometheus.Metric {
+	return metric.MustCreate(infoDesc, 1, []string{
+		host.ID,
+		host.Name,
+		c.clusterName(ctx, host.Cluster.ID),
+		cluster.DataCenterName(ctx, host.Cluster.ID, c.cc.Client()),
+	})
+}
+
+func (c *HostCollector) collectStatisticMetrics(ctx context.Context, host *Host, l []string) {
+	if c.cfg.CollectStatistics {
+		c.collectHostStatistics(ctx, host, l)
+	}
+
+	if !c.cfg.CollectNetwork {
+		return
+	}
+
+	networkPath := fmt.Sprintf("hosts/%s/nics", host.ID)
+	if host.Nics != nil {
+		network.CollectMetricsForNICs(ctx, host.Nics.Nics, networkPath, prefix, labelNames, l, c.cc)
+	} else {
+		network.CollectMetricsForHost(ctx, networkPath, prefix, labelNames, l, c.cc)
+	}
+}
+
+func (c *HostCollector) collectHostStatistics(ctx context.Context, host *Host, l []string) {
+	if host.Statistics != nil {
+		statistic.RecordMetrics(host.Statistics.Statistic, prefix, labelNames, l, c.cc)
+		return
+	}
 
 	statPath := fmt.Sprintf("hosts/%s/statistics", host.ID)
 	statistic.CollectMetrics(ctx, statPath, prefix, labelNames, l, c.cc)
+}
 
-	if c.collectNetwork {
-		networkPath := fmt.Sprintf("hosts/%s/nics", host.ID)
-		network.CollectMetricsForHost(ctx, networkPath, prefix, labelNames, l, c.cc)
+func (c *HostCollector) prefetchClusters(ctx context.Context) {
+	clusters, err := cluster.List(ctx, c.cc.Client())
+	if err != nil {
+		log.Errorf("could not prefetch clusters: %v", err)
+		return
+	}
+
+	c.clusters = make(map[string]string, len(clusters))
+	for _, cl := range clusters {
+		c.clusters[cl.ID] = cl.Name
 	}
 }
 
+func (c *HostCollector) clusterName(ctx context.Context, id string) string {
+	if n, found := c.clusters[id]; found {
+		return n
+	}
+
+	return cluster.Name(ctx, id, c.cc.Client())
+}
+
 func (c *HostCollector) collectCPUMetrics(host *Host, l []string) {
 	topo := host.CPU.Topology
 
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: MIT
+
+package metric
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Filter decides by their names which metrics are exported.
+// Patterns are globs (e.g. ovirt_vm_network_*) or regular expressions enclosed in slashes (e.g. /ovirt_vm_.*_total/).
+type Filter struct {
+	allow     []func(string) bool
+	deny      []func(string) bool
+	decisions sync.Map
+}
+
+// NewFilter creates a filter exporting metrics matching one of the allow patterns (all if empty) and none of the deny patterns
+func NewFilter(allow, deny []string) (*Filter, error) {
+	f := &Filter{}
+
+	var err error
+	f.allow, err = compilePatterns(allow)
+	if err != nil {
+		return nil, err
+	}
+
+	f.deny, err = compilePatterns(deny)
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func compilePatterns(patterns []string) ([]func(string) bool, error) {
+	res := make([]func(string) bool, 0, len(patterns))
+
+	for _, p := range patterns {
+		if len(p) > 1 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/") {
+			re, err := regexp.Compile("^(?:" + p[1:len(p)-1] + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid metric pattern %q: %w", p, err)
+			}
+
+			res = append(res, re.MatchString)
+			continue
+		}
+
+		_, err := path.Match(p, "")
+		if err != nil {
+			return nil, fmt.Errorf("invalid metric pattern %q: %w", p, err)
+		}
+
+		res = append(res, func(name string) bool {
+			matched, _ := path.Match(p, name)
+			return matched
+		})
+	}
+
+	return res, nil
+}
+
+// Allows returns if the metric with the given name is exported. Decisions are cached per name.
+func (f *Filter) Allows(name string) bool {
+	if f == nil || (len(f.allow) == 0 && len(f.deny) == 0) {
+		return true
+	}
+
+	if allowed, found := f.decisions.Load(name); found {
+		return allowed.(bool)
+	}
+
+	allowed := (len(f.allow) == 0 || matchesAny(f.allow, name)) && !matchesAny(f.deny, name)
+	f.decisions.Store(name, allowed)
+
+	return allowed
+}
+
+func matchesAny(matchers []func(string) bool, name string) bool {
+	for _, m := range matchers {
+		if m(name) {
+			return true
+		}
+	}
+
+	return false
+}
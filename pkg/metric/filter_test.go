@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: MIT
+
+package metric
+
+import "testing"
+
+func TestFilterAllows(t *testing.T) {
+	tests := []struct {
+		name     string
+		allow    []string
+		deny     []string
+		metric   string
+		expected bool
+	}{
+		{
+			name:     "no patterns",
+			metric:   "ovirt_vm_up",
+			expected: true,
+		},
+		{
+			name:     "glob allowed",
+			allow:    []string{"ovirt_vm_*"},
+			metric:   "ovirt_vm_up",
+			expected: true,
+		},
+		{
+			name:     "glob not allowed",
+			allow:    []string{"ovirt_vm_*"},
+			metric:   "ovirt_host_up",
+			expected: false,
+		},
+		{
+			name:     "glob matches whole name",
+			allow:    []string{"vm_up"},
+			metric:   "ovirt_vm_up",
+			expected: false,
+		},
+		{
+			name:     "glob character class",
+			deny:     []string{"ovirt_vm_network_[rt]x*"},
+			metric:   "ovirt_vm_network_rx_total",
+			expected: false,
+		},
+		{
+			name:     "regular expression denied",
+			deny:     []string{"/ovirt_host_.*_errors_total/"},
+			metric:   "ovirt_host_network_receive_errors_total",
+			expected: false,
+		},
+		{
+			name:     "regular expression is anchored",
+			deny:     []string{"/host_.*_errors/"},
+			metric:   "ovirt_host_network_receive_errors_total",
+			expected: true,
+		},
+		{
+			name:     "regular expression alternation is anchored",
+			allow:    []string{"/ovirt_vm_up|ovirt_host_up/"},
+			metric:   "ovirt_vm_up_extra",
+			expected: false,
+		},
+		{
+			name:     "slashes without regular expression are a glob",
+			allow:    []string{"/"},
+			metric:   "/",
+			expected: true,
+		},
+		{
+			name:     "deny wins over allow",
+			allow:    []string{"ovirt_vm_*"},
+			deny:     []string{"ovirt_vm_network_*"},
+			metric:   "ovirt_vm_network_rx_total",
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := NewFilter(test.allow, test.deny)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 2; i++ {
+				if got := f.Allows(test.metric); got != test.expected {
+					t.Errorf("got %v, expected %v", got, test.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestNewFilterInvalidPatterns(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+	}{
+		{name: "invalid glob", pattern: "ovirt_[vm"},
+		{name: "invalid regular expression", pattern: "/ovirt_(vm/"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewFilter([]string{test.pattern}, nil)
+			if err == nil {
+				t.Errorf("expected error for pattern %q", test.pattern)
+			}
+		})
+	}
+}
+
+func TestNilFilterAllowsAll(t *testing.T) {
+	var f *Filter
+	if !f.Allows("ovirt_vm_up") {
+		t.Error("expected nil filter to allow all metrics")
+	}
+}
+
+func TestDescName(t *testing.T) {
+	d := NewDesc("ovirt_test_metric", "Test metric", []string{"name"}, nil)
+
+	name, found := DescName(d)
+	if !found || name != "ovirt_test_metric" {
+		t.Errorf("got %q (found: %v), expected ovirt_test_metric", name, found)
+	}
+}
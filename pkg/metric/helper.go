@@ -2,9 +2,72 @@
 
 package metric
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// descNames holds the names of the descriptors created by NewDesc
+	descNames sync.Map
+
+	// names holds the names used by descriptors created by NewDesc
+	names sync.Map
+)
+
+// NewDesc creates a new descriptor (see prometheus.NewDesc) and keeps its name, so metrics can be filtered by their names
+func NewDesc(name, help string, labelNames []string, constLabels prometheus.Labels) *prometheus.Desc {
+	d := prometheus.NewDesc(name, help, labelNames, constLabels)
+	descNames.Store(d, name)
+	names.Store(name, true)
+
+	return d
+}
+
+// Defined returns if a descriptor with the given name was created by NewDesc
+func Defined(name string) bool {
+	_, found := names.Load(name)
+	return found
+}
+
+// DescName returns the name of a descriptor created by NewDesc
+func DescName(d *prometheus.Desc) (string, bool) {
+	name, found := descNames.Load(d)
+	if !found {
+		return "", false
+	}
+
+	return name.(string), true
+}
 
 // MustCreate creates a new prometheus metric
 func MustCreate(desc *prometheus.Desc, v float64, labelValues []string) prometheus.Metric {
 	return prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(v), labelValues...)
 }
+
+// StateSet creates a metric per state with the state as last label value, the current state is 1 and all others 0.
+// A current state missing in states is added, so states unknown to the exporter are not lost.
+func StateSet(desc *prometheus.Desc, states []string, current string, labelValues []string) []prometheus.Metric {
+	if current == "" {
+		return nil
+	}
+
+	metrics := make([]prometheus.Metric, 0, len(states)+1)
+	known := false
+	for _, s := range states {
+		var v float64
+		if s == current {
+			v = 1
+			known = true
+		}
+
+		metrics = append(metrics, MustCreate(desc, v, append(labelValues[:len(labelValues):len(labelValues)], s)))
+	}
+
+	if !known {
+		metrics = append(metrics, MustCreate(desc, 1, append(labelValues[:len(labelValues):len(labelValues)], current)))
+	}
+
+	return metrics
+}
@@ -0,0 +1,38 @@
+// SPDX-License-Identifier: MIT
+
+package metric
+
+import "fmt"
+
+// LabelMode defines how the objects (VMs, hosts, storage domains) are identified by the labels of their metrics
+type LabelMode string
+
+const (
+	// LabelModeNames identifies objects by their names and the names of related objects (e.g. host and cluster)
+	LabelModeNames LabelMode = "names"
+
+	// LabelModeIDs identifies objects by their IDs only. Names and related objects are exported by info metrics.
+	LabelModeIDs LabelMode = "ids"
+)
+
+var labelMode = LabelModeNames
+
+// ParseLabelMode parses the name of a label mode
+func ParseLabelMode(s string) (LabelMode, error) {
+	switch LabelMode(s) {
+	case LabelModeNames, LabelModeIDs:
+		return LabelMode(s), nil
+	default:
+		return "", fmt.Errorf("invalid label mode %q (expected names or ids)", s)
+	}
+}
+
+// SetLabelMode sets the label mode used by all collectors. It has to be set before the first collector is created.
+func SetLabelMode(m LabelMode) {
+	labelMode = m
+}
+
+// IDLabels returns if objects are identified by their IDs
+func IDLabels() bool {
+	return labelMode == LabelModeIDs
+}
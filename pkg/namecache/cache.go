@@ -0,0 +1,152 @@
+// SPDX-License-Identifier: MIT
+
+package namecache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+var (
+	ttl         = time.Hour
+	negativeTTL = time.Minute
+
+	// now returns the current time, replaced in tests to control expiry
+	now = time.Now
+)
+
+// Configure sets the time to live for resolved names and failed lookups of all caches
+func Configure(nameTTL, errorTTL time.Duration) {
+	ttl = nameTTL
+	negativeTTL = errorTTL
+}
+
+// LookupFunc retrieves the name of the object with the given ID from the API
+type LookupFunc func(ctx context.Context, id string) (string, error)
+
+// Cache caches names of API objects by their ID
+type Cache struct {
+	name          string
+	mutex         sync.Mutex
+	entries       map[string]*entry
+	hits          float64
+	misses        float64
+	errors        float64
+	invalidations float64
+}
+
+type entry struct {
+	name    string
+	err     error
+	expires time.Time
+	ready   chan struct{}
+}
+
+func (e *entry) pending() bool {
+	select {
+	case <-e.ready:
+		return false
+	default:
+		return true
+	}
+}
+
+func (e *entry) valid(now time.Time) bool {
+	return e.pending() || now.Before(e.expires)
+}
+
+func newCache(name string) *Cache {
+	return &Cache{
+		name:    name,
+		entries: make(map[string]*entry),
+	}
+}
+
+// Get returns the cached name for the ID. If the name is not cached or expired, lookup is used to retrieve it.
+// Concurrent calls for the same ID share a single lookup, calls for other IDs are not blocked meanwhile.
+func (c *Cache) Get(ctx context.Context, id string, lookup LookupFunc) (string, error) {
+	c.mutex.Lock()
+
+	if e, found := c.entries[id]; found && e.valid(now()) {
+		c.hits++
+		c.mutex.Unlock()
+
+		return c.wait(ctx, e)
+	}
+
+	c.misses++
+	e := &entry{ready: make(chan struct{})}
+	c.entries[id] = e
+	c.mutex.Unlock()
+
+	name, err := lookup(ctx, id)
+
+	c.mutex.Lock()
+	e.name = name
+	e.err = err
+	if err != nil && ctx.Err() != nil {
+		// the lookup was cancelled, so the error says nothing about the object
+		if c.entries[id] == e {
+			delete(c.entries, id)
+		}
+	} else if err != nil {
+		c.errors++
+		e.expires = now().Add(negativeTTL)
+	} else {
+		e.expires = now().Add(ttl)
+	}
+	c.mutex.Unlock()
+	close(e.ready)
+
+	return name, err
+}
+
+func (c *Cache) wait(ctx context.Context, e *entry) (string, error) {
+	select {
+	case <-e.ready:
+		return e.name, e.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Update sets the names retrieved by a list call. Cached names differing from the given ones are replaced.
+func (c *Cache) Update(names map[string]string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	expires := now().Add(ttl)
+	for id, name := range names {
+		e, found := c.entries[id]
+		if found && e.pending() {
+			continue
+		}
+
+		if found && e.err == nil && e.name != name {
+			c.invalidations++
+		}
+
+		e = &entry{
+			name:    name,
+			expires: expires,
+			ready:   make(chan struct{}),
+		}
+		close(e.ready)
+		c.entries[id] = e
+	}
+}
+
+func (c *Cache) size() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	t := now()
+	for id, e := range c.entries {
+		if !e.valid(t) {
+			delete(c.entries, id)
+		}
+	}
+
+	return len(c.entries)
+}
@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: MIT
+
+package namecache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+const testID = "123"
+
+var errLookup = errors.New("lookup failed")
+
+type step struct {
+	advance        time.Duration
+	update         map[string]string
+	cancelled      bool
+	lookupName     string
+	lookupErr      error
+	expectedName   string
+	expectedErr    bool
+	expectedLookup bool
+}
+
+func TestCache(t *testing.T) {
+	tests := []struct {
+		name                  string
+		steps                 []step
+		expectedErrors        float64
+		expectedInvalidations float64
+	}{
+		{
+			name: "cached until TTL expires",
+			steps: []step{
+				{lookupName: "vm1", expectedName: "vm1", expectedLookup: true},
+				{advance: 59 * time.Minute, expectedName: "vm1"},
+				{advance: time.Minute, lookupName: "vm2", expectedName: "vm2", expectedLookup: true},
+			},
+		},
+		{
+			name: "failed lookup cached until negative TTL expires",
+			steps: []step{
+				{lookupErr: errLookup, expectedErr: true, expectedLookup: true},
+				{advance: 59 * time.Second, expectedErr: true},
+				{advance: time.Second, lookupName: "vm1", expectedName: "vm1", expectedLookup: true},
+			},
+			expectedErrors: 1,
+		},
+		{
+			name: "cancelled lookup not cached",
+			steps: []step{
+				{cancelled: true, lookupErr: context.Canceled, expectedErr: true, expectedLookup: true},
+				{lookupName: "vm1", expectedName: "vm1", expectedLookup: true},
+			},
+		},
+		{
+			name: "update replaces changed name",
+			steps: []step{
+				{lookupName: "vm1", expectedName: "vm1", expectedLookup: true},
+				{update: map[string]string{testID: "renamed"}},
+				{expectedName: "renamed"},
+			},
+			expectedInvalidations: 1,
+		},
+		{
+			name: "update renews unchanged name",
+			steps: []step{
+				{lookupName: "vm1", expectedName: "vm1", expectedLookup: true},
+				{advance: 50 * time.Minute, update: map[string]string{testID: "vm1"}},
+				{advance: 50 * time.Minute, expectedName: "vm1"},
+			},
+		},
+		{
+			name: "update replaces failed lookup",
+			steps: []step{
+				{lookupErr: errLookup, expectedErr: true, expectedLookup: true},
+				{update: map[string]string{testID: "vm1"}},
+				{expectedName: "vm1"},
+			},
+			expectedErrors: 1,
+		},
+		{
+			name: "update of other objects keeps name",
+			steps: []step{
+				{lookupName: "vm1", expectedName: "vm1", expectedLookup: true},
+				{update: map[string]string{"456": "vm2"}},
+				{expectedName: "vm1"},
+			},
+		},
+	}
+
+	Configure(time.Hour, time.Minute)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			now = func() time.Time { return clock }
+			defer func() { now = time.Now }()
+
+			c := newCache("test")
+			for i, s := range test.steps {
+				clock = clock.Add(s.advance)
+
+				if s.update != nil {
+					c.Update(s.update)
+					continue
+				}
+
+				ctx, cancel := context.WithCancel(context.Background())
+				if s.cancelled {
+					cancel()
+				}
+
+				called := false
+				name, err := c.Get(ctx, testID, func(ctx context.Context, id string) (string, error) {
+					called = true
+					return s.lookupName, s.lookupErr
+				})
+				cancel()
+
+				if called != s.expectedLookup {
+					t.Errorf("step %d: expected lookup: %v, got %v", i, s.expectedLookup, called)
+				}
+
+				if (err != nil) != s.expectedErr {
+					t.Errorf("step %d: expected error: %v, got %v", i, s.expectedErr, err)
+				}
+
+				if name != s.expectedName {
+					t.Errorf("step %d: expected name %q, got %q", i, s.expectedName, name)
+				}
+			}
+
+			if c.errors != test.expectedErrors {
+				t.Errorf("expected %v errors, got %v", test.expectedErrors, c.errors)
+			}
+
+			if c.invalidations != test.expectedInvalidations {
+				t.Errorf("expected %v invalidations, got %v", test.expectedInvalidations, c.invalidations)
+			}
+		})
+	}
+}
+
+func TestCacheSizeDropsExpiredEntries(t *testing.T) {
+	Configure(time.Hour, time.Minute)
+
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	c := newCache("test")
+	c.Update(map[string]string{"1": "vm1"})
+	c.Get(context.Background(), "2", func(ctx context.Context, id string) (string, error) {
+		return "", errLookup
+	})
+
+	if n := c.size(); n != 2 {
+		t.Fatalf("expected 2 entries, got %d", n)
+	}
+
+	clock = clock.Add(time.Minute)
+	if n := c.size(); n != 1 {
+		t.Fatalf("expected failed lookup to expire, got %d entries", n)
+	}
+
+	clock = clock.Add(time.Hour)
+	if n := c.size(); n != 0 {
+		t.Fatalf("expected all entries to expire, got %d entries", n)
+	}
+}
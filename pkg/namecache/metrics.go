@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: MIT
+
+package namecache
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const prefix = "ovirt_exporter_name_cache_"
+
+var (
+	entriesDesc       *prometheus.Desc
+	hitsDesc          *prometheus.Desc
+	missesDesc        *prometheus.Desc
+	errorsDesc        *prometheus.Desc
+	invalidationsDesc *prometheus.Desc
+)
+
+func init() {
+	l := []string{"cache"}
+	entriesDesc = prometheus.NewDesc(prefix+"entries", "Number of valid entries in the name cache", l, nil)
+	hitsDesc = prometheus.NewDesc(prefix+"hits_total", "Number of names served from the cache", l, nil)
+	missesDesc = prometheus.NewDesc(prefix+"misses_total", "Number of names which had to be retrieved from the API", l, nil)
+	errorsDesc = prometheus.NewDesc(prefix+"lookup_errors_total", "Number of failed name lookups", l, nil)
+	invalidationsDesc = prometheus.NewDesc(prefix+"invalidations_total", "Number of cached names replaced by a list call", l, nil)
+}
+
+// Describe implements Prometheus Collector interface
+func (s *Set) Describe(ch chan<- *prometheus.Desc) {
+	ch <- entriesDesc
+	ch <- hitsDesc
+	ch <- missesDesc
+	ch <- errorsDesc
+	ch <- invalidationsDesc
+}
+
+// Collect implements Prometheus Collector interface
+func (s *Set) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range s.all() {
+		size := c.size()
+
+		c.mutex.Lock()
+		ch <- prometheus.MustNewConstMetric(entriesDesc, prometheus.GaugeValue, float64(size), c.name)
+		ch <- prometheus.MustNewConstMetric(hitsDesc, prometheus.CounterValue, c.hits, c.name)
+		ch <- prometheus.MustNewConstMetric(missesDesc, prometheus.CounterValue, c.misses, c.name)
+		ch <- prometheus.MustNewConstMetric(errorsDesc, prometheus.CounterValue, c.errors, c.name)
+		ch <- prometheus.MustNewConstMetric(invalidationsDesc, prometheus.CounterValue, c.invalidations, c.name)
+		c.mutex.Unlock()
+	}
+}
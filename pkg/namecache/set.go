@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: MIT
+
+package namecache
+
+import "sync"
+
+// defaultSet holds the caches of clients not providing their own set
+var defaultSet = NewSet()
+
+// Provider is implemented by clients providing the name caches of the engine they are connected to
+type Provider interface {
+	// NameCaches returns the caches of the engine (nil if the default caches should be used)
+	NameCaches() *Set
+}
+
+// Set holds the name caches of one engine, so objects of different engines never share cached names
+type Set struct {
+	mutex  sync.Mutex
+	caches map[string]*Cache
+	names  []string
+}
+
+// NewSet creates a new empty set of caches
+func NewSet() *Set {
+	return &Set{
+		caches: make(map[string]*Cache),
+	}
+}
+
+// Cache returns the cache with the given name, creating it on first use. The name is used to identify the cache in metrics.
+func (s *Set) Cache(name string) *Cache {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	c, found := s.caches[name]
+	if !found {
+		c = newCache(name)
+		s.caches[name] = c
+		s.names = append(s.names, name)
+	}
+
+	return c
+}
+
+func (s *Set) all() []*Cache {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	res := make([]*Cache, len(s.names))
+	for i, n := range s.names {
+		res[i] = s.caches[n]
+	}
+
+	return res
+}
+
+// For returns the cache with the given name of the engine the client is connected to
+func For(client interface{}, name string) *Cache {
+	if p, ok := client.(Provider); ok {
+		if s := p.NameCaches(); s != nil {
+			return s.Cache(name)
+		}
+	}
+
+	return defaultSet.Cache(name)
+}
@@ -45,6 +45,17 @@ func CollectMetricsForVM(ctx context.Context, path, prefix string, labelNames, l
 	return collectForNICs(ctx, nics.Nics, path, prefix, labelNames, labelValues, cc)
 }
 
+// CollectMetricsForNICs collects net metrics for NICs already retrieved (e.g. by following links).
+// Statistics not embedded in the NICs are retrieved from the given path.
+func CollectMetricsForNICs(ctx context.Context, nics []Nic, path, prefix string, labelNames, labelValues []string, cc *collector.CollectorContext) error {
+	ctx, span := cc.Tracer().Start(ctx, "Network.CollectForNICs", trace.WithAttributes(
+		attribute.String("prefix", prefix),
+	))
+	defer span.End()
+
+	return collectForNICs(ctx, nics, path, prefix, labelNames, labelValues, cc)
+}
+
 func collectForNICs(ctx context.Context, nics []Nic, path, prefix string, labelNames, labelValues []string, cc *collector.CollectorContext) error {
 	wg := sync.WaitGroup{}
 	wg.Add(len(nics))
@@ -53,6 +64,12 @@ func collectForNICs(ctx context.Context, nics []Nic, path, prefix string, labelN
 		ln := append(labelNames, "nic", "mac")
 		l := append(labelValues, n.Name, n.Mac.Address)
 
+		if n.Statistics != nil {
+			statistic.RecordMetrics(n.Statistics.Statistic, prefix+"network_", ln, l, cc)
+			wg.Done()
+			continue
+		}
+
 		go func() {
 			statistic.CollectMetrics(ctx, p, prefix+"network_", ln, l, cc)
 			wg.Done()
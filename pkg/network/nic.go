@@ -2,6 +2,8 @@
 
 package network
 
+import "github.com/czerwonk/ovirt_exporter/pkg/statistic"
+
 // HostNics is a collection of NICs of a host
 type HostNics struct {
 	Nics []Nic `xml:"host_nic"`
@@ -19,4 +21,5 @@ type Nic struct {
 	Mac  struct {
 		Address string `xml:"address"`
 	} `xml:"mac"`
+	Statistics *statistic.Statistics `xml:"statistics,omitempty"`
 }
@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: MIT
+
+package poller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/czerwonk/ovirt_exporter/pkg/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const prefix = "ovirt_exporter_"
+
+var (
+	lastRefreshDesc     *prometheus.Desc
+	refreshFailuresDesc *prometheus.Desc
+)
+
+func init() {
+	l := []string{"collector"}
+	lastRefreshDesc = prometheus.NewDesc(prefix+"last_refresh_timestamp_seconds", "Timestamp of the last complete refresh of the collector", l, nil)
+	refreshFailuresDesc = prometheus.NewDesc(prefix+"refresh_failures_total", "Number of refreshes which could not be completed without errors", l, nil)
+}
+
+// Factory creates a new collector instance for a single refresh cycle
+type Factory func(ctx context.Context, cc *collector.CollectorContext) prometheus.Collector
+
+// Poller refreshes collectors in the background and keeps the last complete set of metrics in memory
+type Poller struct {
+	cc      *collector.CollectorContext
+	targets []*target
+}
+
+type target struct {
+	name        string
+	interval    time.Duration
+	factory     Factory
+	mutex       sync.RWMutex
+	metrics     []prometheus.Metric
+	lastRefresh time.Time
+	failures    float64
+}
+
+// New creates a new poller
+func New(cc *collector.CollectorContext) *Poller {
+	return &Poller{
+		cc: cc,
+	}
+}
+
+// Add adds a collector refreshed in the given interval
+func (p *Poller) Add(name string, interval time.Duration, factory Factory) {
+	p.targets = append(p.targets, &target{
+		name:     name,
+		interval: interval,
+		factory:  factory,
+	})
+}
+
+// Run refreshes all collectors until the context is cancelled
+func (p *Poller) Run(ctx context.Context) {
+	wg := &sync.WaitGroup{}
+	wg.Add(len(p.targets))
+
+	for _, t := range p.targets {
+		go func() {
+			defer wg.Done()
+			p.runTarget(ctx, t)
+		}()
+	}
+
+	wg.Wait()
+}
+
+func (p *Poller) runTarget(ctx context.Context, t *target) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		p.refresh(ctx, t)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Poller) refresh(ctx context.Context, t *target) {
+	// a refresh must not delay the next one
+	ctx, cancel := context.WithTimeout(ctx, t.interval)
+	defer cancel()
+
+	ctx, span := p.cc.Tracer().Start(ctx, "Poller.Refresh", trace.WithAttributes(
+		attribute.String("collector", t.name),
+	))
+	defer span.End()
+
+	cc := p.cc.Clone()
+	c := t.factory(ctx, cc)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	metrics := make([]prometheus.Metric, 0)
+	for m := range ch {
+		metrics = append(metrics, m)
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if cc.ErrorCount() > 0 {
+		log.Warnf("Refresh of collector %s was incomplete (%d errors), keeping previous metrics", t.name, cc.ErrorCount())
+		t.failures++
+		return
+	}
+
+	t.metrics = metrics
+	t.lastRefresh = time.Now()
+}
+
+// Describe implements Prometheus Collector interface
+func (p *Poller) Describe(ch chan<- *prometheus.Desc) {
+	ch <- lastRefreshDesc
+	ch <- refreshFailuresDesc
+}
+
+// Collect implements Prometheus Collector interface
+func (p *Poller) Collect(ch chan<- prometheus.Metric) {
+	for _, t := range p.targets {
+		t.mutex.RLock()
+
+		for _, m := range t.metrics {
+			ch <- m
+		}
+
+		if !t.lastRefresh.IsZero() {
+			ch <- prometheus.MustNewConstMetric(lastRefreshDesc, prometheus.GaugeValue, float64(t.lastRefresh.UnixNano())/1e9, t.name)
+		}
+		ch <- prometheus.MustNewConstMetric(refreshFailuresDesc, prometheus.CounterValue, t.failures, t.name)
+
+		t.mutex.RUnlock()
+	}
+}
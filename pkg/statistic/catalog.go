@@ -0,0 +1,200 @@
+// SPDX-License-Identifier: MIT
+
+package statistic
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	legacyNames        = false
+	invalidCharsRegex  = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+	bytesPerBit        = 1.0 / 8
+	ratioPerPercentage = 0.01
+)
+
+// Configure enables the legacy metric names derived from the names and units of the statistics (without unit conversion)
+func Configure(legacy bool) {
+	legacyNames = legacy
+}
+
+// catalogEntry maps a statistic reported by the engine to a stable metric name (without prefix) in base units
+type catalogEntry struct {
+	name  string
+	help  string
+	scale float64
+
+	// supersededBy is a statistic reporting the same value more precisely (the entry is ignored if it is present)
+	supersededBy string
+
+	// multiValued statistics report a list of values, exported with an index label.
+	// Only the first value of other statistics (including the ones missing in the catalog) is exported.
+	multiValued bool
+}
+
+// catalog contains the statistics of VMs, hosts and NICs known to be reported by oVirt engines
+var catalog = map[string]catalogEntry{
+	// VMs and hosts
+	"memory.installed":       {name: "memory_installed_bytes", help: "Memory installed in bytes", scale: 1},
+	"memory.used":            {name: "memory_used_bytes", help: "Memory used in bytes", scale: 1},
+	"memory.free":            {name: "memory_free_bytes", help: "Memory free in bytes", scale: 1},
+	"memory.total":           {name: "memory_total_bytes", help: "Total memory in bytes", scale: 1},
+	"memory.buffered":        {name: "memory_buffered_bytes", help: "Memory used for buffers in bytes", scale: 1},
+	"memory.buffers":         {name: "memory_buffered_bytes", help: "Memory used for buffers in bytes", scale: 1},
+	"memory.cached":          {name: "memory_cached_bytes", help: "Memory used for caches in bytes", scale: 1},
+	"memory.unused":          {name: "memory_unused_bytes", help: "Memory unused by the guest in bytes", scale: 1},
+	"memory.shared":          {name: "memory_shared_bytes", help: "Memory shared in bytes", scale: 1},
+	"swap.total":             {name: "swap_total_bytes", help: "Total swap space in bytes", scale: 1},
+	"swap.free":              {name: "swap_free_bytes", help: "Swap space free in bytes", scale: 1},
+	"swap.used":              {name: "swap_used_bytes", help: "Swap space used in bytes", scale: 1},
+	"swap.cached":            {name: "swap_cached_bytes", help: "Swap space cached in bytes", scale: 1},
+	"cpu.current.guest":      {name: "cpu_guest_ratio", help: "Ratio of CPU used by the guest", scale: ratioPerPercentage},
+	"cpu.current.hypervisor": {name: "cpu_hypervisor_ratio", help: "Ratio of CPU used by the hypervisor for the VM", scale: ratioPerPercentage},
+	"cpu.current.total":      {name: "cpu_total_ratio", help: "Ratio of CPU used in total", scale: ratioPerPercentage},
+	"cpu.current.user":       {name: "cpu_user_ratio", help: "Ratio of CPU used by user processes", scale: ratioPerPercentage},
+	"cpu.current.system":     {name: "cpu_system_ratio", help: "Ratio of CPU used by the system", scale: ratioPerPercentage},
+	"cpu.current.idle":       {name: "cpu_idle_ratio", help: "Ratio of CPU idle", scale: ratioPerPercentage},
+	"cpu.load.avg.5m":        {name: "cpu_load_average_5m", help: "CPU load average of the last 5 minutes", scale: 1},
+	"ksm.cpu.current":        {name: "ksm_cpu_ratio", help: "Ratio of CPU used by kernel same page merging", scale: ratioPerPercentage},
+	"migration.progress":     {name: "migration_progress_ratio", help: "Progress of the running migration", scale: ratioPerPercentage},
+	"network.current.total":  {name: "network_usage_ratio", help: "Ratio of network bandwidth used", scale: ratioPerPercentage},
+	"elapsed.time":           {name: "elapsed_time_seconds", help: "Time since the VM was started in seconds", scale: 1},
+	"boot.time":              {name: "boot_time_seconds", help: "Time the host was booted (seconds since epoch)", scale: 1},
+	"hugepages.2048.free":    {name: "hugepages_2m_free", help: "Number of free huge pages of 2 MiB", scale: 1},
+	"hugepages.1048576.free": {name: "hugepages_1g_free", help: "Number of free huge pages of 1 GiB", scale: 1},
+	"cpu.usage.history":      {name: "cpu_usage_history_ratio", help: "Recent ratios of CPU used", scale: ratioPerPercentage, multiValued: true},
+	"memory.usage.history":   {name: "memory_usage_history_ratio", help: "Recent ratios of memory used", scale: ratioPerPercentage, multiValued: true},
+	"network.usage.history":  {name: "network_usage_history_ratio", help: "Recent ratios of network bandwidth used", scale: ratioPerPercentage, multiValued: true},
+
+	// NICs
+	"data.current.rx":     {name: "receive_rate_bytes_per_second", help: "Receive data rate in bytes per second", scale: 1, supersededBy: "data.current.rx.bps"},
+	"data.current.tx":     {name: "transmit_rate_bytes_per_second", help: "Transmit data rate in bytes per second", scale: 1, supersededBy: "data.current.tx.bps"},
+	"data.current.rx.bps": {name: "receive_rate_bytes_per_second", help: "Receive data rate in bytes per second", scale: bytesPerBit},
+	"data.current.tx.bps": {name: "transmit_rate_bytes_per_second", help: "Transmit data rate in bytes per second", scale: bytesPerBit},
+	"data.total.rx":       {name: "receive_bytes_total", help: "Total data received in bytes", scale: 1},
+	"data.total.tx":       {name: "transmit_bytes_total", help: "Total data transmitted in bytes", scale: 1},
+	"errors.total.rx":     {name: "receive_errors_total", help: "Total number of receive errors", scale: 1},
+	"errors.total.tx":     {name: "transmit_errors_total", help: "Total number of transmit errors", scale: 1},
+}
+
+// metricDef defines the metric a statistic is exported as
+type metricDef struct {
+	name      string
+	help      string
+	scale     float64
+	valueType prometheus.ValueType
+
+	// info metrics have the value of a string statistic as label
+	info bool
+
+	// indexed metrics have the position of each value of a multi-valued statistic as label (decided by the catalog, not by the number of values reported)
+	indexed bool
+}
+
+// definition returns the metric the statistic is exported as.
+// Statistics of unknown types or superseded by one of the present ones are skipped (ok = false).
+func definition(s Statistic, prefix string, present map[string]bool) (def metricDef, ok bool) {
+	if len(s.Values.Value) == 0 {
+		return def, false
+	}
+
+	if s.Type == "string" {
+		name := invalidCharsRegex.ReplaceAllString(s.Name, "_") + "_info"
+		return metricDef{
+			name:      prefix + name,
+			help:      s.Description,
+			scale:     1,
+			valueType: prometheus.GaugeValue,
+			info:      true,
+			indexed:   catalog[s.Name].multiValued,
+		}, true
+	}
+
+	if s.Type != "decimal" && s.Type != "integer" {
+		return def, false
+	}
+
+	var valueType prometheus.ValueType
+	switch s.Kind {
+	case "gauge":
+		valueType = prometheus.GaugeValue
+	case "counter":
+		valueType = prometheus.CounterValue
+	default:
+		return def, false
+	}
+
+	def, ok = lookup(s, prefix, valueType, present)
+	def.valueType = valueType
+	def.indexed = catalog[s.Name].multiValued
+
+	return def, ok
+}
+
+// lookup returns the metric a numeric statistic is exported as. Statistics superseded by one of the present ones are skipped (ok = false).
+func lookup(s Statistic, prefix string, valueType prometheus.ValueType, present map[string]bool) (def metricDef, ok bool) {
+	if legacyNames {
+		return metricDef{name: legacyName(s, prefix, valueType), help: s.Description, scale: 1}, true
+	}
+
+	e, found := catalog[s.Name]
+	if !found {
+		return fallback(s, prefix, valueType), true
+	}
+
+	if e.supersededBy != "" && present[e.supersededBy] {
+		return def, false
+	}
+
+	return metricDef{name: prefix + e.name, help: e.help, scale: e.scale}, true
+}
+
+// fallback derives the metric name of a statistic missing in the catalog from its name and unit converted to base units
+func fallback(s Statistic, prefix string, valueType prometheus.ValueType) metricDef {
+	unit, scale := baseUnit(s.Unit)
+
+	name := invalidCharsRegex.ReplaceAllString(s.Name, "_")
+	if unit != "" {
+		name += "_" + unit
+	}
+
+	return metricDef{name: prefix + counterName(name, valueType), help: s.Description, scale: scale}
+}
+
+// baseUnit returns the base unit and the factor converting values of the unit reported by the engine
+func baseUnit(unit string) (string, float64) {
+	switch unit {
+	case "none", "":
+		return "", 1
+	case "percent":
+		return "ratio", ratioPerPercentage
+	case "bits":
+		return "bytes", bytesPerBit
+	case "bits_per_second":
+		return "bytes_per_second", bytesPerBit
+	default:
+		return invalidCharsRegex.ReplaceAllString(unit, "_"), 1
+	}
+}
+
+func legacyName(s Statistic, prefix string, valueType prometheus.ValueType) string {
+	metricName := strings.Replace(s.Name, ".", "_", -1)
+
+	if s.Unit != "none" {
+		metricName += "_" + s.Unit
+	}
+
+	return prefix + counterName(metricName, valueType)
+}
+
+// counterName suffixes counter metrics with '_total' to follow Prometheus best practices
+func counterName(name string, valueType prometheus.ValueType) string {
+	if valueType != prometheus.CounterValue {
+		return name
+	}
+
+	return strings.ReplaceAll(name, "_total", "") + "_total"
+}
@@ -0,0 +1,181 @@
+// SPDX-License-Identifier: MIT
+
+package statistic
+
+import (
+	"testing"
+
+	"github.com/czerwonk/ovirt_exporter/pkg/metric"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func stat(name, typ, kind, unit string, values ...Value) Statistic {
+	s := Statistic{
+		Name:        name,
+		Description: name,
+		Type:        typ,
+		Kind:        kind,
+		Unit:        unit,
+	}
+	s.Values.Value = values
+
+	return s
+}
+
+func datum(values ...float64) []Value {
+	res := make([]Value, len(values))
+	for i, v := range values {
+		res[i] = Value{Datum: v}
+	}
+
+	return res
+}
+
+func TestDefinitionNamesAndScaling(t *testing.T) {
+	tests := []struct {
+		name      string
+		prefix    string
+		stat      Statistic
+		present   []string
+		metric    string
+		scale     float64
+		valueType prometheus.ValueType
+		skipped   bool
+	}{
+		{
+			name:      "catalog percent as ratio",
+			stat:      stat("cpu.current.guest", "decimal", "gauge", "percent", datum(50)...),
+			metric:    "ovirt_vm_cpu_guest_ratio",
+			scale:     0.01,
+			valueType: prometheus.GaugeValue,
+		},
+		{
+			name:      "catalog bits per second as bytes per second",
+			prefix:    "ovirt_vm_network_",
+			stat:      stat("data.current.rx.bps", "decimal", "gauge", "bits_per_second", datum(800)...),
+			metric:    "ovirt_vm_network_receive_rate_bytes_per_second",
+			scale:     0.125,
+			valueType: prometheus.GaugeValue,
+		},
+		{
+			name:      "catalog bytes unscaled",
+			stat:      stat("memory.used", "integer", "gauge", "bytes", datum(1024)...),
+			metric:    "ovirt_vm_memory_used_bytes",
+			scale:     1,
+			valueType: prometheus.GaugeValue,
+		},
+		{
+			name:      "catalog counter",
+			prefix:    "ovirt_vm_network_",
+			stat:      stat("data.total.rx", "integer", "counter", "bytes", datum(1)...),
+			metric:    "ovirt_vm_network_receive_bytes_total",
+			scale:     1,
+			valueType: prometheus.CounterValue,
+		},
+		{
+			name:    "superseded by present statistic",
+			prefix:  "ovirt_vm_network_",
+			stat:    stat("data.current.rx", "decimal", "gauge", "bytes_per_second", datum(100)...),
+			present: []string{"data.current.rx", "data.current.rx.bps"},
+			skipped: true,
+		},
+		{
+			name:      "superseding statistic absent",
+			prefix:    "ovirt_vm_network_",
+			stat:      stat("data.current.rx", "decimal", "gauge", "bytes_per_second", datum(100)...),
+			present:   []string{"data.current.rx"},
+			metric:    "ovirt_vm_network_receive_rate_bytes_per_second",
+			scale:     1,
+			valueType: prometheus.GaugeValue,
+		},
+		{
+			name:      "unknown percent converted to ratio",
+			stat:      stat("disk.current.busy", "decimal", "gauge", "percent", datum(10)...),
+			metric:    "ovirt_vm_disk_current_busy_ratio",
+			scale:     0.01,
+			valueType: prometheus.GaugeValue,
+		},
+		{
+			name:      "unknown bits converted to bytes",
+			stat:      stat("data.total.sent", "integer", "counter", "bits", datum(8)...),
+			metric:    "ovirt_vm_data_sent_bytes_total",
+			scale:     0.125,
+			valueType: prometheus.CounterValue,
+		},
+		{
+			name:      "unknown without unit",
+			stat:      stat("vcpu.count", "integer", "gauge", "none", datum(4)...),
+			metric:    "ovirt_vm_vcpu_count",
+			scale:     1,
+			valueType: prometheus.GaugeValue,
+		},
+		{
+			name:    "unknown kind",
+			stat:    stat("cpu.current.guest", "decimal", "histogram", "percent", datum(50)...),
+			skipped: true,
+		},
+		{
+			name:    "no values",
+			stat:    stat("cpu.current.guest", "decimal", "gauge", "percent"),
+			skipped: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			present := make(map[string]bool)
+			for _, p := range test.present {
+				present[p] = true
+			}
+
+			prefix := test.prefix
+			if prefix == "" {
+				prefix = "ovirt_vm_"
+			}
+
+			def, ok := definition(test.stat, prefix, present)
+			if ok == test.skipped {
+				t.Fatalf("got ok %v, expected %v", ok, !test.skipped)
+			}
+
+			if test.skipped {
+				return
+			}
+
+			if def.name != test.metric {
+				t.Errorf("got name %s, expected %s", def.name, test.metric)
+			}
+
+			if def.scale != test.scale {
+				t.Errorf("got scale %v, expected %v", def.scale, test.scale)
+			}
+
+			if def.valueType != test.valueType {
+				t.Errorf("got value type %v, expected %v", def.valueType, test.valueType)
+			}
+		})
+	}
+}
+
+func TestDefinitionLegacyNames(t *testing.T) {
+	Configure(true)
+	defer Configure(false)
+
+	def, ok := definition(stat("cpu.current.guest", "decimal", "gauge", "percent", datum(50)...), "ovirt_vm_", nil)
+	if !ok {
+		t.Fatal("expected statistic to be exported")
+	}
+
+	if def.name != "ovirt_vm_cpu_current_guest_percent" || def.scale != 1 {
+		t.Errorf("got name %s with scale %v, expected ovirt_vm_cpu_current_guest_percent with scale 1", def.name, def.scale)
+	}
+}
+
+func TestRegisterCollidingWithCollectorMetric(t *testing.T) {
+	metric.NewDesc("ovirt_test_memory_installed_bytes", "Memory installed", []string{"name"}, nil)
+
+	_, err := register(metricDef{name: "ovirt_test_memory_installed_bytes", valueType: prometheus.GaugeValue}, []string{"name"})
+	if err == nil {
+		t.Error("expected collision with metric of a collector")
+	}
+}
@@ -5,14 +5,32 @@ package statistic
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/czerwonk/ovirt_exporter/pkg/collector"
+	"github.com/czerwonk/ovirt_exporter/pkg/metric"
 	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
 
+var (
+	metricsMutex sync.Mutex
+	metrics      = make(map[string]*registeredMetric)
+	collisions   = make(map[string]bool)
+	truncated    = make(map[string]bool)
+)
+
+// registeredMetric is a metric created from statistics. The descriptor is shared by all objects to keep help texts consistent.
+type registeredMetric struct {
+	desc       *prometheus.Desc
+	valueType  prometheus.ValueType
+	labelNames string
+}
+
 // CollectMetrics collects metrics by statics returned by a given url
 func CollectMetrics(ctx context.Context, path, prefix string, labelNames, labelValues []string, cc *collector.CollectorContext) {
 	ctx, span := cc.Tracer().Start(ctx, "Statistic.CollectMetrics", trace.WithAttributes(
@@ -26,32 +44,127 @@ func CollectMetrics(ctx context.Context, path, prefix string, labelNames, labelV
 		cc.HandleError(err, span)
 	}
 
-	for _, s := range stats.Statistic {
-		if s.Type != "decimal" && s.Type != "integer" {
+	RecordMetrics(stats.Statistic, prefix, labelNames, labelValues, cc)
+}
+
+// RecordMetrics records metrics for statistics already retrieved (e.g. by following links).
+// String statistics are recorded as info metrics (value as label), multi-valued statistics of the catalog with the index of each value as label.
+// Only the first value is recorded for all other statistics, so the labels of a metric do not depend on the number of values reported.
+// Statistics colliding with a metric created for another statistic are dropped.
+func RecordMetrics(stats []Statistic, prefix string, labelNames, labelValues []string, cc *collector.CollectorContext) {
+	present := make(map[string]bool, len(stats))
+	for _, s := range stats {
+		present[s.Name] = true
+	}
+
+	recorded := make(map[string]string, len(stats))
+	for _, s := range stats {
+		def, ok := definition(s, prefix, present)
+		if !ok || !cc.MetricAllowed(def.name) {
+			continue
+		}
+
+		if other, found := recorded[def.name]; found {
+			reportCollision(def.name, s.Name, "statistic "+other)
+			continue
+		}
+
+		names := labelNames
+		if def.indexed {
+			names = append(names[:len(names):len(names)], "index")
+		}
+
+		if def.info {
+			names = append(names[:len(names):len(names)], "value")
+		}
+
+		d, err := register(def, names)
+		if err != nil {
+			reportCollision(def.name, s.Name, err.Error())
 			continue
 		}
-		switch s.Kind {
-		case "gauge":
-			cc.RecordMetrics(convertToMetric(s, prefix, labelNames, labelValues, prometheus.GaugeValue))
-		case "counter":
-			cc.RecordMetrics(convertToMetric(s, prefix, labelNames, labelValues, prometheus.CounterValue))
+		recorded[def.name] = s.Name
+
+		vals := s.Values.Value
+		if !def.indexed && len(vals) > 1 {
+			reportTruncation(def.name, s.Name, len(vals))
+			vals = vals[:1]
+		}
+
+		for i, v := range vals {
+			values := labelValues[:len(labelValues):len(labelValues)]
+			if def.indexed {
+				values = append(values, strconv.Itoa(i))
+			}
+
+			value := v.Datum * def.scale
+			if def.info {
+				values = append(values, v.Detail)
+				value = 1
+			}
+
+			cc.RecordMetrics(prometheus.MustNewConstMetric(d, def.valueType, value, values...))
 		}
 	}
 }
 
-func convertToMetric(s Statistic, prefix string, labelNames, labelValues []string, valueType prometheus.ValueType) prometheus.Metric {
-	metricName := strings.Replace(s.Name, ".", "_", -1)
+// register returns the descriptor of the metric.
+// An error is returned if the name is already used by a static metric of a collector or with another type or labels.
+func register(def metricDef, labelNames []string) (*prometheus.Desc, error) {
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+
+	labels := strings.Join(labelNames, ",")
+
+	m, found := metrics[def.name]
+	if !found && metric.Defined(def.name) {
+		return nil, fmt.Errorf("metric of a collector")
+	}
 
-	if s.Unit != "none" {
-		metricName += "_" + s.Unit
+	if !found {
+		m = &registeredMetric{
+			desc:       metric.NewDesc(def.name, def.help, labelNames, nil),
+			valueType:  def.valueType,
+			labelNames: labels,
+		}
+		metrics[def.name] = m
 	}
 
-	if valueType == prometheus.CounterValue {
-		// Suffix counter metrics with '_total' to follow Prometheus best practices.
-		metricName = strings.ReplaceAll(metricName, "_total", "")
-		metricName = metricName + "_total"
+	if m.valueType != def.valueType {
+		return nil, fmt.Errorf("metric of another type")
+	}
+
+	if m.labelNames != labels {
+		return nil, fmt.Errorf("metric with labels %s", m.labelNames)
+	}
+
+	return m.desc, nil
+}
+
+// reportCollision logs a statistic dropped because of a collision (once per metric and statistic)
+func reportCollision(name, statistic, reason string) {
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+
+	key := name + " " + statistic
+	if collisions[key] {
+		return
+	}
+	collisions[key] = true
+
+	log.Warnf("dropping statistic %s: metric name %s collides with %s", statistic, name, reason)
+}
+
+// reportTruncation logs a statistic reporting multiple values without being known as multi-valued by the catalog (once per metric and statistic)
+func reportTruncation(name, statistic string, count int) {
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+
+	key := name + " " + statistic
+	if truncated[key] {
+		return
 	}
-	d := prometheus.NewDesc(fmt.Sprint(prefix, metricName), s.Description, labelNames, nil)
+	truncated[key] = true
 
-	return prometheus.MustNewConstMetric(d, valueType, float64(s.Values.Value.Datum), labelValues...)
+	log.Warnf("statistic %s reports %d values, only the first one is exported as %s (multi-valued statistics have to be added to the catalog)", statistic, count, name)
 }
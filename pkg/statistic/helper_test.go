@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: MIT
+
+package statistic
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/czerwonk/ovirt_exporter/pkg/collector"
+	"github.com/czerwonk/ovirt_exporter/pkg/metric"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// record returns the metrics recorded for the statistics as name{labels} value
+func record(t *testing.T, prefix string, stats ...Statistic) []string {
+	cc := collector.NewContext(noop.NewTracerProvider().Tracer(""), nil)
+
+	ch := make(chan prometheus.Metric, 100)
+	cc.SetMetricsCh(ch)
+	RecordMetrics(stats, prefix, []string{"name"}, []string{"vm1"}, cc)
+	close(ch)
+
+	res := make([]string, 0)
+	for m := range ch {
+		pb := &dto.Metric{}
+		err := m.Write(pb)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		labels := make([]string, 0, len(pb.Label))
+		for _, l := range pb.Label {
+			labels = append(labels, l.GetName()+"="+l.GetValue())
+		}
+
+		name, _ := metric.DescName(m.Desc())
+		value := pb.GetGauge().GetValue() + pb.GetCounter().GetValue()
+		res = append(res, name+"{"+strings.Join(labels, ",")+"} "+strconv.FormatFloat(value, 'g', -1, 64))
+	}
+	sort.Strings(res)
+
+	return res
+}
+
+func TestRecordMetricsLabels(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefix   string
+		stats    []Statistic
+		expected []string
+		warnings int
+	}{
+		{
+			name:   "single value",
+			prefix: "ovirt_test_single_",
+			stats:  []Statistic{stat("cpu.current.guest", "decimal", "gauge", "percent", datum(50)...)},
+			expected: []string{
+				"ovirt_test_single_cpu_guest_ratio{name=vm1} 0.5",
+			},
+		},
+		{
+			name:   "multi-valued statistic of the catalog",
+			prefix: "ovirt_test_multi_",
+			stats:  []Statistic{stat("cpu.usage.history", "decimal", "gauge", "percent", datum(10, 20)...)},
+			expected: []string{
+				"ovirt_test_multi_cpu_usage_history_ratio{index=0,name=vm1} 0.1",
+				"ovirt_test_multi_cpu_usage_history_ratio{index=1,name=vm1} 0.2",
+			},
+		},
+		{
+			name:   "multi-valued statistic of the catalog reporting a single value",
+			prefix: "ovirt_test_multi_single_",
+			stats:  []Statistic{stat("cpu.usage.history", "decimal", "gauge", "percent", datum(10)...)},
+			expected: []string{
+				"ovirt_test_multi_single_cpu_usage_history_ratio{index=0,name=vm1} 0.1",
+			},
+		},
+		{
+			name:   "unknown statistic reporting multiple values drops further values",
+			prefix: "ovirt_test_unknown_",
+			stats:  []Statistic{stat("foo.bar", "integer", "gauge", "none", datum(1, 2)...)},
+			expected: []string{
+				"ovirt_test_unknown_foo_bar{name=vm1} 1",
+			},
+			warnings: 1,
+		},
+		{
+			name:   "string statistic",
+			prefix: "ovirt_test_info_",
+			stats:  []Statistic{stat("guest.os", "string", "gauge", "none", Value{Detail: "linux"}, Value{Detail: "other"})},
+			expected: []string{
+				"ovirt_test_info_guest_os_info{name=vm1,value=linux} 1",
+			},
+			warnings: 1,
+		},
+		{
+			name:   "colliding statistics",
+			prefix: "ovirt_test_collision_",
+			stats: []Statistic{
+				stat("memory.buffered", "integer", "gauge", "bytes", datum(1)...),
+				stat("memory.buffers", "integer", "gauge", "bytes", datum(2)...),
+			},
+			expected: []string{
+				"ovirt_test_collision_memory_buffered_bytes{name=vm1} 1",
+			},
+			warnings: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hook := logtest.NewGlobal()
+			defer hook.Reset()
+
+			got := record(t, test.prefix, test.stats...)
+
+			if strings.Join(got, "\n") != strings.Join(test.expected, "\n") {
+				t.Errorf("got:\n%s\nexpected:\n%s", strings.Join(got, "\n"), strings.Join(test.expected, "\n"))
+			}
+
+			if len(hook.AllEntries()) != test.warnings {
+				t.Errorf("got %d warnings, expected %d", len(hook.AllEntries()), test.warnings)
+			}
+		})
+	}
+}
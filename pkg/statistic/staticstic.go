@@ -15,8 +15,12 @@ type Statistic struct {
 	Type        string `xml:"type"`
 	Unit        string `xml:"unit"`
 	Values      struct {
-		Value struct {
-			Datum float64 `xml:"datum"`
-		} `xml:"value"`
+		Value []Value `xml:"value"`
 	} `xml:"values"`
 }
+
+// Value represents a single value of a statistic (numeric values in Datum, strings in Detail)
+type Value struct {
+	Datum  float64 `xml:"datum"`
+	Detail string  `xml:"detail"`
+}
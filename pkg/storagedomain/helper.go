@@ -4,19 +4,14 @@ package storagedomain
 
 import (
 	"context"
-	"sync"
 
 	"fmt"
 
 	"github.com/czerwonk/ovirt_exporter/pkg/collector"
+	"github.com/czerwonk/ovirt_exporter/pkg/namecache"
 	log "github.com/sirupsen/logrus"
 )
 
-var (
-	cacheMutex = sync.Mutex{}
-	nameCache  = make(map[string]string)
-)
-
 // Get retrieves domain information
 func Get(ctx context.Context, id string, cl collector.Client) (*StorageDomain, error) {
 	path := fmt.Sprintf("storagedomains/%s", id)
@@ -32,24 +27,59 @@ func Get(ctx context.Context, id string, cl collector.Client) (*StorageDomain, e
 
 // Name retrieves domain name
 func Name(ctx context.Context, id string, cl collector.Client) string {
-	cacheMutex.Lock()
-	defer cacheMutex.Unlock()
+	n, _ := namecache.For(cl, "storage_domain").Get(ctx, id, func(ctx context.Context, id string) (string, error) {
+		d, err := Get(ctx, id, cl)
+		if err != nil {
+			log.Error(err)
+			return "", err
+		}
+
+		if d == nil {
+			err = fmt.Errorf("could not find name for storage domain with ID %s", id)
+			log.Error(err)
+			return "", err
+		}
+
+		return d.Name, nil
+	})
 
-	if n, found := nameCache[id]; found {
-		return n
+	return n
+}
+
+// UpdateNames updates the cached names with domains retrieved by a list call
+func UpdateNames(domains []StorageDomain, cl collector.Client) {
+	names := make(map[string]string, len(domains))
+	for _, d := range domains {
+		names[d.ID] = d.Name
 	}
 
-	d, err := Get(ctx, id, cl)
+	namecache.For(cl, "storage_domain").Update(names)
+}
+
+// List retrieves all storage domains
+func List(ctx context.Context, cl collector.Client) ([]StorageDomain, error) {
+	return Search(ctx, "", cl)
+}
+
+// Search retrieves the storage domains matching the search query (empty = all)
+func Search(ctx context.Context, query string, cl collector.Client) ([]StorageDomain, error) {
+	s := StorageDomains{}
+	err := cl.GetAndParse(ctx, collector.ListPath("storagedomains", query, nil), &s)
 	if err != nil {
-		log.Error(err)
-		return ""
+		return nil, err
 	}
 
-	if d == nil {
-		log.Errorf("could not find name for storage domain with ID %s", id)
-		return ""
+	UpdateNames(s.Domains, cl)
+	return s.Domains, nil
+}
+
+// ListAttached retrieves the storage domains attached to the data center. The status of attached domains is only reported in this context.
+func ListAttached(ctx context.Context, dataCenterID string, cl collector.Client) ([]StorageDomain, error) {
+	s := StorageDomains{}
+	err := cl.GetAndParse(ctx, fmt.Sprintf("datacenters/%s/storagedomains", dataCenterID), &s)
+	if err != nil {
+		return nil, err
 	}
 
-	nameCache[id] = d.Name
-	return d.Name
+	return s.Domains, nil
 }
@@ -2,6 +2,29 @@
 
 package storagedomain
 
+// Statuses are the states of a storage domain defined by the API
+var Statuses = []string{
+	"activating",
+	"active",
+	"detaching",
+	"inactive",
+	"locked",
+	"maintenance",
+	"mixed",
+	"preparing_for_maintenance",
+	"unattached",
+	"unknown",
+}
+
+// ExternalStatuses are the states of a storage domain reported by external systems defined by the API
+var ExternalStatuses = []string{
+	"error",
+	"failure",
+	"info",
+	"ok",
+	"warning",
+}
+
 // StorageDomains is a collection of storage domains
 type StorageDomains struct {
 	Domains []StorageDomain `xml:"storage_domain"`
@@ -19,6 +42,7 @@ type StorageDomain struct {
 	Available      float64 ` xml:"available,omitempty"`
 	Committed      float64 `xml:"committed,omitempty"`
 	Used           float64 `xml:"used,omitempty"`
+	Status         string  `xml:"status,omitempty"`
 	ExternalStatus string  `xml:"external_status,omitempty"`
 	Master         bool    `xml:"master,omitempty"`
 	DataCenters    struct {
@@ -4,10 +4,14 @@ package storagedomain
 
 import (
 	"context"
+	"sync"
 
 	"github.com/czerwonk/ovirt_exporter/pkg/collector"
+	"github.com/czerwonk/ovirt_exporter/pkg/datacenter"
+	"github.com/czerwonk/ovirt_exporter/pkg/filter"
 	"github.com/czerwonk/ovirt_exporter/pkg/metric"
 	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
 )
 
 const prefix = "ovirt_storage_"
@@ -18,29 +22,54 @@ var (
 	committedDesc *prometheus.Desc
 	masterDesc    *prometheus.Desc
 	upDesc        *prometheus.Desc
+	infoDesc      *prometheus.Desc
+	statusDesc    *prometheus.Desc
+	extStatusDesc *prometheus.Desc
+	descsOnce     sync.Once
 )
 
-func init() {
+// initDescs creates the descriptors according to the label mode (once before the first collection)
+func initDescs() {
 	l := []string{"name", "type", "path"}
-	availableDesc = prometheus.NewDesc(prefix+"available_bytes", "Available space in bytes", l, nil)
-	usedDesc = prometheus.NewDesc(prefix+"used_bytes", "Used space in bytes", l, nil)
-	committedDesc = prometheus.NewDesc(prefix+"committed_bytes", "Committed space in bytes", l, nil)
-	upDesc = prometheus.NewDesc(prefix+"up", "Status of storage domain", l, nil)
-	masterDesc = prometheus.NewDesc(prefix+"master", "Storage domain is master", l, nil)
+	if metric.IDLabels() {
+		l = []string{"storage_domain_id"}
+	}
+
+	infoDesc = metric.NewDesc(prefix+"info", "Names and properties of the storage domain", []string{"storage_domain_id", "name", "type", "path", "datacenter"}, nil)
+	availableDesc = metric.NewDesc(prefix+"available_bytes", "Available space in bytes", l, nil)
+	usedDesc = metric.NewDesc(prefix+"used_bytes", "Used space in bytes", l, nil)
+	committedDesc = metric.NewDesc(prefix+"committed_bytes", "Committed space in bytes", l, nil)
+	upDesc = metric.NewDesc(prefix+"up", "Status of storage domain", l, nil)
+	masterDesc = metric.NewDesc(prefix+"master", "Storage domain is master", l, nil)
+	statusDesc = metric.NewDesc(prefix+"status", "Status of the storage domain (1 for the current status, 0 for all others)", append(l, "status"), nil)
+	extStatusDesc = metric.NewDesc(prefix+"external_status", "Status of the storage domain reported by external systems (1 for the current status, 0 for all others)", append(l, "status"), nil)
+}
+
+// Config defines which metrics are retrieved by the collector
+type Config struct {
+	// Search restricts the collected storage domains to the ones matching the search query (empty = all)
+	Search string
+
+	// Filter restricts the collected storage domains by name (nil = all)
+	Filter *filter.Filter
 }
 
 // StorageDomainCollector collects storage domain statistics from oVirt
 type StorageDomainCollector struct {
 	cc              *collector.CollectorContext
+	cfg             Config
 	collectDuration prometheus.Observer
 	rootCtx         context.Context
 }
 
 // NewCollector creates a new collector
-func NewCollector(ctx context.Context, cc *collector.CollectorContext, collectDuration prometheus.Observer) prometheus.Collector {
+func NewCollector(ctx context.Context, cc *collector.CollectorContext, cfg Config, collectDuration prometheus.Observer) prometheus.Collector {
+	descsOnce.Do(initDescs)
+
 	return &StorageDomainCollector{
 		rootCtx:         ctx,
 		cc:              cc,
+		cfg:             cfg,
 		collectDuration: collectDuration,
 	}
 }
@@ -55,15 +84,53 @@ func (c *StorageDomainCollector) Collect(ch chan<- prometheus.Metric) {
 	timer := prometheus.NewTimer(c.collectDuration)
 	defer timer.ObserveDuration()
 
-	s := StorageDomains{}
-	err := c.cc.Client().GetAndParse(ctx, "storagedomains", &s)
+	domains, err := Search(ctx, c.cfg.Search, c.cc.Client())
 	if err != nil {
 		c.cc.HandleError(err, span)
 		return
 	}
 
-	for _, h := range s.Domains {
-		c.collectMetricsForDomain(h)
+	matching := make([]StorageDomain, 0, len(domains))
+	for _, d := range domains {
+		if c.cfg.Filter.Matches(filter.Object{Name: d.Name}) {
+			matching = append(matching, d)
+		}
+	}
+
+	c.resolveStatuses(ctx, matching)
+
+	for _, d := range matching {
+		c.collectMetricsForDomain(ctx, d)
+	}
+}
+
+// resolveStatuses sets the status of domains attached to a data center, which is missing in the list of all storage domains.
+// The attached domains are retrieved once per data center.
+func (c *StorageDomainCollector) resolveStatuses(ctx context.Context, domains []StorageDomain) {
+	statuses := make(map[string]string)
+	retrieved := make(map[string]bool)
+
+	for i := range domains {
+		d := &domains[i]
+		dcID := d.DataCenters.DataCenter.ID
+		if d.Status != "" || dcID == "" {
+			continue
+		}
+
+		if !retrieved[dcID] {
+			retrieved[dcID] = true
+
+			attached, err := ListAttached(ctx, dcID, c.cc.Client())
+			if err != nil {
+				log.Errorf("could not retrieve storage domains of data center %s: %v", dcID, err)
+			}
+
+			for _, a := range attached {
+				statuses[a.ID] = a.Status
+			}
+		}
+
+		d.Status = statuses[d.ID]
 	}
 }
 
@@ -74,12 +141,29 @@ func (c *StorageDomainCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- availableDesc
 	ch <- usedDesc
 	ch <- committedDesc
+	ch <- statusDesc
+	ch <- extStatusDesc
+
+	if metric.IDLabels() {
+		ch <- infoDesc
+	}
 }
 
-func (c *StorageDomainCollector) collectMetricsForDomain(domain StorageDomain) {
+func (c *StorageDomainCollector) collectMetricsForDomain(ctx context.Context, domain StorageDomain) {
 	d := &domain
 	l := []string{d.Name, string(d.Type), d.Storage.Path}
 
+	if metric.IDLabels() {
+		l = []string{d.ID}
+		c.cc.RecordMetrics(metric.MustCreate(infoDesc, 1, []string{
+			d.ID,
+			d.Name,
+			string(d.Type),
+			d.Storage.Path,
+			datacenter.Name(ctx, d.DataCenters.DataCenter.ID, c.cc.Client()),
+		}))
+	}
+
 	up := d.ExternalStatus == "ok"
 	c.cc.RecordMetrics(
 		metric.MustCreate(upDesc, boolToFloat(up), l),
@@ -88,6 +172,8 @@ func (c *StorageDomainCollector) collectMetricsForDomain(domain StorageDomain) {
 		metric.MustCreate(usedDesc, float64(d.Used), l),
 		metric.MustCreate(committedDesc, float64(d.Committed), l),
 	)
+	c.cc.RecordMetrics(metric.StateSet(statusDesc, Statuses, d.Status, l)...)
+	c.cc.RecordMetrics(metric.StateSet(extStatusDesc, ExternalStatuses, d.ExternalStatus, l)...)
 }
 
 func boolToFloat(b bool) float64 {
@@ -0,0 +1,142 @@
+// SPDX-License-Identifier: MIT
+
+package vm
+
+import (
+	"context"
+	"sync"
+
+	"github.com/czerwonk/ovirt_exporter/pkg/cluster"
+	"github.com/czerwonk/ovirt_exporter/pkg/collector"
+	"github.com/czerwonk/ovirt_exporter/pkg/disk"
+	"github.com/czerwonk/ovirt_exporter/pkg/host"
+	"github.com/czerwonk/ovirt_exporter/pkg/storagedomain"
+	log "github.com/sirupsen/logrus"
+)
+
+// references resolves hosts, clusters and disks referenced by VMs from lists retrieved once per collection.
+// Objects missing in the lists are retrieved by individual requests.
+type references struct {
+	cl             collector.Client
+	hosts          map[string]string
+	clusters       map[string]string
+	storageDomains map[string]string
+	disks          map[string]*disk.Disk
+}
+
+func newReferences(cl collector.Client) *references {
+	return &references{
+		cl:             cl,
+		hosts:          make(map[string]string),
+		clusters:       make(map[string]string),
+		storageDomains: make(map[string]string),
+		disks:          make(map[string]*disk.Disk),
+	}
+}
+
+// prefetch retrieves hosts and clusters. Storage domains are retrieved if withDomains or withDisks is set.
+func (r *references) prefetch(ctx context.Context, withDomains, withDisks bool) {
+	wg := &sync.WaitGroup{}
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		r.prefetchHosts(ctx)
+	}()
+
+	go func() {
+		defer wg.Done()
+		r.prefetchClusters(ctx)
+	}()
+
+	if withDomains || withDisks {
+		r.prefetchStorageDomains(ctx)
+	}
+
+	if withDisks {
+		r.prefetchDisks(ctx)
+	}
+
+	wg.Wait()
+}
+
+func (r *references) prefetchHosts(ctx context.Context) {
+	hosts, err := host.List(ctx, r.cl)
+	if err != nil {
+		log.Errorf("could not prefetch hosts: %v", err)
+		return
+	}
+
+	for _, h := range hosts {
+		r.hosts[h.ID] = h.Name
+	}
+}
+
+func (r *references) prefetchClusters(ctx context.Context) {
+	clusters, err := cluster.List(ctx, r.cl)
+	if err != nil {
+		log.Errorf("could not prefetch clusters: %v", err)
+		return
+	}
+
+	for _, c := range clusters {
+		r.clusters[c.ID] = c.Name
+	}
+}
+
+func (r *references) prefetchStorageDomains(ctx context.Context) {
+	domains, err := storagedomain.List(ctx, r.cl)
+	if err != nil {
+		log.Errorf("could not prefetch storage domains: %v", err)
+		return
+	}
+
+	for _, d := range domains {
+		r.storageDomains[d.ID] = d.Name
+	}
+}
+
+func (r *references) prefetchDisks(ctx context.Context) {
+	disks, err := disk.List(ctx, r.storageDomains, r.cl)
+	if err != nil {
+		log.Errorf("could not prefetch disks: %v", err)
+		return
+	}
+
+	for i := range disks {
+		r.disks[disks[i].ID] = &disks[i]
+	}
+}
+
+func (r *references) hostName(ctx context.Context, id string) string {
+	if n, found := r.hosts[id]; found {
+		return n
+	}
+
+	return host.Name(ctx, id, r.cl)
+}
+
+func (r *references) clusterName(ctx context.Context, id string) string {
+	if n, found := r.clusters[id]; found {
+		return n
+	}
+
+	return cluster.Name(ctx, id, r.cl)
+}
+
+// dataCenterName returns the name of the data center the cluster belongs to
+func (r *references) dataCenterName(ctx context.Context, clusterID string) string {
+	return cluster.DataCenterName(ctx, clusterID, r.cl)
+}
+
+func (r *references) disk(ctx context.Context, id string) (*disk.Disk, error) {
+	if d, found := r.disks[id]; found {
+		return d, nil
+	}
+
+	return disk.Get(ctx, id, r.cl)
+}
+
+func (r *references) resolveStorageDomains(ctx context.Context, d *disk.Disk) {
+	disk.ResolveStorageDomains(ctx, d, r.storageDomains, r.cl)
+}
@@ -2,6 +2,30 @@
 
 package vm
 
+import (
+	"github.com/czerwonk/ovirt_exporter/pkg/network"
+	"github.com/czerwonk/ovirt_exporter/pkg/statistic"
+)
+
+// Statuses are the states of a VM defined by the API
+var Statuses = []string{
+	"down",
+	"image_locked",
+	"migrating",
+	"not_responding",
+	"paused",
+	"powering_down",
+	"powering_up",
+	"reboot_in_progress",
+	"restoring_state",
+	"saving_state",
+	"suspended",
+	"unassigned",
+	"unknown",
+	"up",
+	"wait_for_launch",
+}
+
 // VMs is a collection of virtual machines
 type VMs struct {
 	VMs []VM `xml:"vm"`
@@ -26,4 +50,10 @@ type VM struct {
 		} `xml:"topology"`
 	} `xml:"cpu"`
 	HasIllegalImages bool `xml:"has_illegal_images"`
+
+	// linked collections, only present if retrieved by following links
+	Statistics      *statistic.Statistics `xml:"statistics,omitempty"`
+	Nics            *network.VMNics       `xml:"nics,omitempty"`
+	DiskAttachments *DiskAttachments      `xml:"disk_attachments,omitempty"`
+	Snapshots       *Snapshots            `xml:"snapshots,omitempty"`
 }
@@ -10,14 +10,14 @@ import (
 
 	"time"
 
-	"github.com/czerwonk/ovirt_exporter/pkg/cluster"
 	"github.com/czerwonk/ovirt_exporter/pkg/collector"
 	"github.com/czerwonk/ovirt_exporter/pkg/disk"
-	"github.com/czerwonk/ovirt_exporter/pkg/host"
+	"github.com/czerwonk/ovirt_exporter/pkg/filter"
 	"github.com/czerwonk/ovirt_exporter/pkg/metric"
 	"github.com/czerwonk/ovirt_exporter/pkg/network"
 	"github.com/czerwonk/ovirt_exporter/pkg/statistic"
 	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -36,48 +36,96 @@ var (
 	diskProvisionedSize *prometheus.Desc
 	diskActualSize      *prometheus.Desc
 	diskTotalSize       *prometheus.Desc
+	diskInfoDesc        *prometheus.Desc
+	infoDesc            *prometheus.Desc
+	statusDesc          *prometheus.Desc
 	labelNames          []string
+	descsOnce           sync.Once
 )
 
-func init() {
+// initDescs creates the descriptors according to the label mode (once before the first collection)
+func initDescs() {
 	labelNames = []string{"name", "host", "cluster"}
-	upDesc = prometheus.NewDesc(prefix+"up", "VM is running (1) or not (0)", labelNames, nil)
-	cpuCoresDesc = prometheus.NewDesc(prefix+"cpu_cores", "Number of CPU cores assigned", labelNames, nil)
-	cpuSocketsDesc = prometheus.NewDesc(prefix+"cpu_sockets", "Number of sockets", labelNames, nil)
-	cpuThreadsDesc = prometheus.NewDesc(prefix+"cpu_threads", "Number of threads", labelNames, nil)
-	snapshotCount = prometheus.NewDesc(prefix+"snapshots", "Number of snapshots", labelNames, nil)
-	maxSnapshotAge = prometheus.NewDesc(prefix+"snapshot_max_age_seconds", "Age of the oldest snapshot in seconds", labelNames, nil)
-	minSnapshotAge = prometheus.NewDesc(prefix+"snapshot_min_age_seconds", "Age of the newest snapshot in seconds", labelNames, nil)
-	illegalImages = prometheus.NewDesc(prefix+"illegal_images", "Health status of the disks attatched to the VM (1 if one or more disk is in illegal state)", labelNames, nil)
+	if metric.IDLabels() {
+		labelNames = []string{"vm_id"}
+	}
+
+	infoDesc = metric.NewDesc(prefix+"info", "Names of the VM and the objects it belongs to", []string{"vm_id", "name", "host", "cluster", "datacenter"}, nil)
+	upDesc = metric.NewDesc(prefix+"up", "VM is running (1) or not (0)", labelNames, nil)
+	statusDesc = metric.NewDesc(prefix+"status", "Status of the VM (1 for the current status, 0 for all others)", append(labelNames, "status"), nil)
+	cpuCoresDesc = metric.NewDesc(prefix+"cpu_cores", "Number of CPU cores assigned", labelNames, nil)
+	cpuSocketsDesc = metric.NewDesc(prefix+"cpu_sockets", "Number of sockets", labelNames, nil)
+	cpuThreadsDesc = metric.NewDesc(prefix+"cpu_threads", "Number of threads", labelNames, nil)
+	snapshotCount = metric.NewDesc(prefix+"snapshots", "Number of snapshots", labelNames, nil)
+	maxSnapshotAge = metric.NewDesc(prefix+"snapshot_max_age_seconds", "Age of the oldest snapshot in seconds", labelNames, nil)
+	minSnapshotAge = metric.NewDesc(prefix+"snapshot_min_age_seconds", "Age of the newest snapshot in seconds", labelNames, nil)
+	illegalImages = metric.NewDesc(prefix+"illegal_images", "Health status of the disks attatched to the VM (1 if one or more disk is in illegal state)", labelNames, nil)
 
 	diskLabelNames := append(labelNames, "disk_name", "disk_alias", "disk_logical_name", "storage_domain", "disk_id")
-	diskProvisionedSize = prometheus.NewDesc(prefix+"disk_provisioned_size_bytes", "Provisioned size of the disk in bytes", diskLabelNames, nil)
-	diskActualSize = prometheus.NewDesc(prefix+"disk_actual_size_bytes", "Actual size of the disk in bytes", diskLabelNames, nil)
-	diskTotalSize = prometheus.NewDesc(prefix+"disk_total_size_bytes", "Total size of the disk in bytes", diskLabelNames, nil)
+	if metric.IDLabels() {
+		diskLabelNames = []string{"vm_id", "storage_domain_id", "disk_id"}
+	}
+
+	diskInfoDesc = metric.NewDesc(prefix+"disk_info", "Names of the disk and the storage domain it belongs to", []string{"vm_id", "disk_id", "disk_name", "disk_alias", "disk_logical_name", "storage_domain"}, nil)
+	diskProvisionedSize = metric.NewDesc(prefix+"disk_provisioned_size_bytes", "Provisioned size of the disk in bytes", diskLabelNames, nil)
+	diskActualSize = metric.NewDesc(prefix+"disk_actual_size_bytes", "Actual size of the disk in bytes", diskLabelNames, nil)
+	diskTotalSize = metric.NewDesc(prefix+"disk_total_size_bytes", "Total size of the disk in bytes", diskLabelNames, nil)
+}
+
+// Config defines which metrics are retrieved by the collector
+type Config struct {
+	// Tier defines which kind of resources are queried
+	Tier collector.Tier
+
+	// CollectStatistics enables VM statistics (statistics)
+	CollectStatistics bool
+
+	// CollectSnapshots enables snapshot metrics (inventory)
+	CollectSnapshots bool
+
+	// CollectNetwork enables NIC metrics (statistics)
+	CollectNetwork bool
+
+	// CollectDisks enables disk metrics (inventory)
+	CollectDisks bool
+
+	// Prefetch retrieves referenced hosts, clusters and disks as lists once per collection
+	Prefetch bool
+
+	// Follow retrieves sub resources (statistics, NICs, disk attachments, snapshots) embedded in the VM list
+	Follow bool
+
+	// Search restricts the collected VMs to the ones matching the search query (empty = all)
+	Search string
+
+	// Shard restricts the collected VMs to the ones belonging to the shard of this instance
+	Shard collector.Shard
 
+	// Filter restricts the collected VMs by name, cluster, host, tag and status (nil = all)
+	Filter *filter.Filter
 }
 
 // VMCollector collects virtual machine statistics from oVirt
 type VMCollector struct {
-	cc               *collector.CollectorContext
-	collectDuration  prometheus.Observer
-	metrics          []prometheus.Metric
-	collectSnapshots bool
-	collectNetwork   bool
-	collectDisks     bool
-	mutex            sync.Mutex
-	rootCtx          context.Context
+	cc              *collector.CollectorContext
+	collectDuration prometheus.Observer
+	metrics         []prometheus.Metric
+	cfg             Config
+	refs            *references
+	mutex           sync.Mutex
+	rootCtx         context.Context
 }
 
 // NewCollector creates a new collector
-func NewCollector(ctx context.Context, cc *collector.CollectorContext, collectSnaphots, collectNetwork bool, collectDisks bool, collectDuration prometheus.Observer) prometheus.Collector {
+func NewCollector(ctx context.Context, cc *collector.CollectorContext, cfg Config, collectDuration prometheus.Observer) prometheus.Collector {
+	descsOnce.Do(initDescs)
+
 	return &VMCollector{
-		cc:               cc,
-		collectSnapshots: collectSnaphots,
-		collectNetwork:   collectNetwork,
-		collectDisks:     collectDisks,
-		collectDuration:  collectDuration,
-		rootCtx:          ctx,
+		cc:              cc,
+		cfg:             cfg,
+		refs:            newReferences(cc.Client()),
+		collectDuration: collectDuration,
+		rootCtx:         ctx,
 	}
 }
 
@@ -117,19 +165,30 @@ func (c *VMCollector) retrieveMetrics(ctx context.Context, span trace.Span) {
 	timer := prometheus.NewTimer(c.collectDuration)
 	defer timer.ObserveDuration()
 
-	v := VMs{}
-	err := c.cc.Client().GetAndParse(ctx, "vms", &v)
+	vms, err := c.retrieveVMs(ctx)
+	if err != nil {
+		c.cc.HandleError(err, span)
+		return
+	}
+	vms = c.inShard(vms)
+
+	if c.cfg.Prefetch {
+		withDisks := c.cfg.CollectDisks && c.cfg.Tier.Includes(collector.TierInventory)
+		c.refs.prefetch(ctx, withDisks && c.cfg.Follow, withDisks && !c.cfg.Follow)
+	}
+
+	vms, err = c.filter(ctx, vms)
 	if err != nil {
 		c.cc.HandleError(err, span)
 		return
 	}
 
 	wg := &sync.WaitGroup{}
-	wg.Add(len(v.VMs))
+	wg.Add(len(vms))
 
 	ch := make(chan prometheus.Metric)
 	c.cc.SetMetricsCh(ch)
-	for _, v := range v.VMs {
+	for _, v := range vms {
 		go c.collectForVM(ctx, v, wg)
 	}
 
@@ -143,6 +202,134 @@ func (c *VMCollector) retrieveMetrics(ctx context.Context, span trace.Span) {
 	}
 }
 
+func (c *VMCollector) retrieveVMs(ctx context.Context) ([]VM, error) {
+	links := c.listLinks()
+	if len(links) > 0 {
+		v := VMs{}
+		err := c.cc.Client().GetAndParse(ctx, collector.ListPath("vms", c.search(), links), &v)
+		if err == nil {
+			return v.VMs, nil
+		}
+
+		log.Warnf("could not retrieve VMs following links (%v), falling back to individual requests", err)
+	}
+
+	v := VMs{}
+	err := c.cc.Client().GetAndParse(ctx, collector.ListPath("vms", c.search(), nil), &v)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.VMs, nil
+}
+
+// search returns the search query of the VM list restricted by the scope and the include criteria of the filter
+func (c *VMCollector) search() string {
+	return collector.JoinSearch(c.cfg.Search, c.cfg.Filter.Search())
+}
+
+func (c *VMCollector) inShard(vms []VM) []VM {
+	if c.cfg.Shard.Count <= 1 {
+		return vms
+	}
+
+	res := make([]VM, 0, len(vms)/c.cfg.Shard.Count+1)
+	for _, v := range vms {
+		if c.cfg.Shard.Includes(v.ID) {
+			res = append(res, v)
+		}
+	}
+
+	return res
+}
+
+// filter returns the VMs matching the filter. VMs having a tag used by the filter are retrieved by one search per tag.
+func (c *VMCollector) filter(ctx context.Context, vms []VM) ([]VM, error) {
+	f := c.cfg.Filter
+	if f.Searchable() {
+		return vms, nil
+	}
+
+	tags, err := c.retrieveTags(ctx, f.Tags())
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]VM, 0, len(vms))
+	for _, v := range vms {
+		o := filter.Object{
+			Name:    v.Name,
+			Status:  v.Status,
+			Cluster: func() string { return c.refs.clusterName(ctx, v.Cluster.ID) },
+			Host:    func() string { return c.hostName(ctx, &v) },
+			Tags:    func() []string { return tags[v.ID] },
+		}
+
+		if f.Matches(o) {
+			res = append(res, v)
+		}
+	}
+
+	return res, nil
+}
+
+// retrieveTags returns the given tags assigned to VMs by VM ID
+func (c *VMCollector) retrieveTags(ctx context.Context, tags []string) (map[string][]string, error) {
+	res := make(map[string][]string)
+	for _, t := range tags {
+		v := VMs{}
+		err := c.cc.Client().GetAndParse(ctx, collector.ListPath("vms", collector.JoinSearch(c.search(), collector.SearchTerm("tag", t)), nil), &v)
+		if err != nil {
+			return nil, fmt.Errorf("could not retrieve VMs with tag %s: %w", t, err)
+		}
+
+		for _, vm := range v.VMs {
+			res[vm.ID] = append(res[vm.ID], t)
+		}
+	}
+
+	return res, nil
+}
+
+// listLinks returns the links embedded in the VM list. VMs of other shards or not matching the filter are dropped after listing,
+// so the sub resources are requested individually for the remaining VMs instead of embedding them for all VMs.
+func (c *VMCollector) listLinks() []string {
+	if c.cfg.Shard.Count > 1 || !c.cfg.Filter.Searchable() {
+		return nil
+	}
+
+	return c.followLinks()
+}
+
+func (c *VMCollector) followLinks() []string {
+	if !c.cfg.Follow {
+		return nil
+	}
+
+	links := make([]string, 0)
+	if c.cfg.Tier.Includes(collector.TierInventory) {
+		if c.cfg.CollectSnapshots {
+			links = append(links, "snapshots")
+		}
+
+		if c.cfg.CollectDisks {
+			links = append(links, "disk_attachments.disk")
+		}
+	}
+
+	if c.cfg.Tier.Includes(collector.TierStatistics) {
+		if c.cfg.CollectStatistics {
+			links = append(links, "statistics")
+		}
+
+		if c.cfg.CollectNetwork {
+			links = append(links, "nics.statistics")
+		}
+	}
+
+	return links
+}
+
 func (c *VMCollector) collectForVM(ctx context.Context, vm VM, wg *sync.WaitGroup) {
 	defer wg.Done()
 
@@ -153,30 +340,72 @@ func (c *VMCollector) collectForVM(ctx context.Context, vm VM, wg *sync.WaitGrou
 	defer span.End()
 
 	v := &vm
-	l := []string{v.Name, c.hostName(ctx, v), cluster.Name(ctx, v.Cluster.ID, c.cc.Client())}
+	l := c.labelValues(ctx, v)
+
+	if c.cfg.Tier.Includes(collector.TierInventory) {
+		c.collectInventoryMetrics(ctx, v, l)
+	}
+
+	if c.cfg.Tier.Includes(collector.TierStatistics) {
+		c.collectStatisticMetrics(ctx, v, l)
+	}
+}
+
+func (c *VMCollector) labelValues(ctx context.Context, vm *VM) []string {
+	if metric.IDLabels() {
+		return []string{vm.ID}
+	}
+
+	return []string{vm.Name, c.hostName(ctx, vm), c.refs.clusterName(ctx, vm.Cluster.ID)}
+}
+
+func (c *VMCollector) collectInventoryMetrics(ctx context.Context, vm *VM, l []string) {
+	if metric.IDLabels() {
+		c.cc.RecordMetrics(c.infoMetric(ctx, vm))
+	}
 
 	c.cc.RecordMetrics(
-		c.upMetric(v, l),
-		c.diskImageIllegalMetric(v, l),
+		c.upMetric(vm, l),
+		c.diskImageIllegalMetric(vm, l),
 	)
+	c.cc.RecordMetrics(metric.StateSet(statusDesc, Statuses, vm.Status, l)...)
 
-	c.collectCPUMetrics(v, l)
+	c.collectCPUMetrics(vm, l)
 
-	statPath := fmt.Sprintf("vms/%s/statistics", vm.ID)
-	statistic.CollectMetrics(ctx, statPath, prefix, labelNames, l, c.cc)
+	if c.cfg.CollectSnapshots {
+		c.collectSnapshotMetrics(ctx, vm, l)
+	}
 
-	if c.collectNetwork {
-		networkPath := fmt.Sprintf("vms/%s/nics", vm.ID)
-		network.CollectMetricsForVM(ctx, networkPath, prefix, labelNames, l, c.cc)
+	if c.cfg.CollectDisks {
+		c.collectDiskMetrics(ctx, vm, l)
 	}
+}
 
-	if c.collectSnapshots {
-		c.collectSnapshotMetrics(ctx, v, l)
+func (c *VMCollector) collectStatisticMetrics(ctx context.Context, vm *VM, l []string) {
+	if c.cfg.CollectStatistics {
+		c.collectVMStatistics(ctx, vm, l)
 	}
 
-	if c.collectDisks {
-		c.collectDiskMetrics(ctx, v, l)
+	if !c.cfg.CollectNetwork {
+		return
+	}
+
+	networkPath := fmt.Sprintf("vms/%s/nics", vm.ID)
+	if vm.Nics != nil {
+		network.CollectMetricsForNICs(ctx, vm.Nics.Nics, networkPath, prefix, labelNames, l, c.cc)
+	} else {
+		network.CollectMetricsForVM(ctx, networkPath, prefix, labelNames, l, c.cc)
+	}
+}
+
+func (c *VMCollector) collectVMStatistics(ctx context.Context, vm *VM, l []string) {
+	if vm.Statistics != nil {
+		statistic.RecordMetrics(vm.Statistics.Statistic, prefix, labelNames, l, c.cc)
+		return
 	}
+
+	statPath := fmt.Sprintf("vms/%s/statistics", vm.ID)
+	statistic.CollectMetrics(ctx, statPath, prefix, labelNames, l, c.cc)
 }
 
 func (c *VMCollector) collectCPUMetrics(vm *VM, l []string) {
@@ -194,7 +423,17 @@ func (c *VMCollector) hostName(ctx context.Context, vm *VM) string {
 		return ""
 	}
 
-	return host.Name(ctx, vm.Host.ID, c.cc.Client())
+	return c.refs.hostName(ctx, vm.Host.ID)
+}
+
+func (c *VMCollector) infoMetric(ctx context.Context, vm *VM) prometheus.Metric {
+	return metric.MustCreate(infoDesc, 1, []string{
+		vm.ID,
+		vm.Name,
+		c.hostName(ctx, vm),
+		c.refs.clusterName(ctx, vm.Cluster.ID),
+		c.refs.dataCenterName(ctx, vm.Cluster.ID),
+	})
 }
 
 func (c *VMCollector) upMetric(vm *VM, labelValues []string) prometheus.Metric {
@@ -219,12 +458,19 @@ func (c *VMCollector) collectSnapshotMetrics(ctx context.Context, vm *VM, l []st
 	ctx, span := c.cc.Tracer().Start(ctx, "VMCollector.CollectSnapshotMetrics")
 	defer span.End()
 
-	snaps := Snapshots{}
-	path := fmt.Sprintf("vms/%s/snapshots", vm.ID)
+	snaps := vm.Snapshots
+	if snaps == nil {
+		snaps = &Snapshots{}
+		path := fmt.Sprintf("vms/%s/snapshots", vm.ID)
 
-	err := c.cc.Client().GetAndParse(ctx, path, &snaps)
-	if err != nil {
-		c.cc.HandleError(err, span)
+		err := c.cc.Client().GetAndParse(ctx, path, snaps)
+		if err != nil {
+			c.cc.HandleError(err, span)
+			return
+		}
+	}
+
+	if len(snaps.Snapshot) == 0 {
 		return
 	}
 
@@ -249,13 +495,17 @@ func (c *VMCollector) collectDiskMetrics(ctx context.Context, vm *VM, l []string
 	ctx, span := c.cc.Tracer().Start(ctx, "VMCollector.CollectDiskMetrics")
 	defer span.End()
 
-	attchs := DiskAttachments{}
-	path := fmt.Sprintf("vms/%s/diskattachments", vm.ID)
-
-	err := c.cc.Client().GetAndParse(ctx, path, &attchs)
-	if err != nil {
-		c.cc.HandleError(err, span)
-		return
+	attchs := vm.DiskAttachments
+	embedded := attchs != nil
+	if !embedded {
+		attchs = &DiskAttachments{}
+		path := fmt.Sprintf("vms/%s/diskattachments", vm.ID)
+
+		err := c.cc.Client().GetAndParse(ctx, path, attchs)
+		if err != nil {
+			c.cc.HandleError(err, span)
+			return
+		}
 	}
 
 	if len(attchs.Attachment) == 0 {
@@ -266,19 +516,19 @@ func (c *VMCollector) collectDiskMetrics(ctx context.Context, vm *VM, l []string
 	wg.Add(len(attchs.Attachment))
 
 	for _, a := range attchs.Attachment {
-		go c.collectForAttachment(ctx, a, l, wg)
+		go c.collectForAttachment(ctx, a, embedded, l, wg)
 	}
 
 	wg.Wait()
 }
 
-func (c *VMCollector) collectForAttachment(ctx context.Context, attachment DiskAttachment, l []string, wg *sync.WaitGroup) {
+func (c *VMCollector) collectForAttachment(ctx context.Context, attachment DiskAttachment, embedded bool, l []string, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	ctx, span := c.cc.Tracer().Start(ctx, "VMCollector.CollectAttachement")
 	defer span.End()
 
-	d, err := disk.Get(ctx, attachment.Disk.ID, c.cc.Client())
+	d, err := c.attachedDisk(ctx, attachment, embedded)
 	if err != nil {
 		c.cc.HandleError(err, span)
 		return
@@ -289,7 +539,13 @@ func (c *VMCollector) collectForAttachment(ctx context.Context, attachment DiskA
 		return
 	}
 
-	l = append(l, d.Name, d.Alias, attachment.LogicalName, d.StorageDomainName(), attachment.Disk.ID)
+	l = l[:len(l):len(l)]
+	if metric.IDLabels() {
+		c.cc.RecordMetrics(metric.MustCreate(diskInfoDesc, 1, append(l, attachment.Disk.ID, d.Name, d.Alias, attachment.LogicalName, d.StorageDomainName())))
+		l = append(l, d.StorageDomainID(), attachment.Disk.ID)
+	} else {
+		l = append(l, d.Name, d.Alias, attachment.LogicalName, d.StorageDomainName(), attachment.Disk.ID)
+	}
 
 	c.cc.RecordMetrics(
 		metric.MustCreate(diskProvisionedSize, float64(d.ProvisionedSize), l),
@@ -297,3 +553,14 @@ func (c *VMCollector) collectForAttachment(ctx context.Context, attachment DiskA
 		metric.MustCreate(diskTotalSize, float64(d.TotalSize), l),
 	)
 }
+
+func (c *VMCollector) attachedDisk(ctx context.Context, attachment DiskAttachment, embedded bool) (*disk.Disk, error) {
+	if !embedded {
+		return c.refs.disk(ctx, attachment.Disk.ID)
+	}
+
+	d := attachment.Disk
+	c.refs.resolveStorageDomains(ctx, &d)
+
+	return &d, nil
+}
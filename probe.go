@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/czerwonk/ovirt_exporter/pkg/config"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// probeEngineTTL is the time the connection to a probed engine is kept open without being probed
+const probeEngineTTL = 15 * time.Minute
+
+var errTooManyProbeEngines = errors.New("too many probed engines")
+
+// prober keeps the connections to engines probed by /probe requests
+type prober struct {
+	ctx     context.Context
+	cfg     *config.Config
+	mutex   sync.Mutex
+	engines map[string]*probedEngine
+}
+
+type probedEngine struct {
+	*engine
+	lastUsed time.Time
+}
+
+func newProber(ctx context.Context, cfg *config.Config) *prober {
+	return &prober{
+		ctx:     ctx,
+		cfg:     cfg,
+		engines: make(map[string]*probedEngine),
+	}
+}
+
+// engine returns the engine for target using the settings of module.
+// Engines are reused for subsequent probes, engines not probed for probeEngineTTL are closed.
+// New targets are rejected while the number of open engines reaches the limit set by probe.max-engines.
+func (p *prober) engine(module, target string) (*engine, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.expire()
+
+	key := module + " " + target
+	if e, found := p.engines[key]; found {
+		e.lastUsed = time.Now()
+		return e.engine, nil
+	}
+
+	if *probeMaxEngines > 0 && len(p.engines) >= *probeMaxEngines {
+		return nil, fmt.Errorf("%w (limit: %d)", errTooManyProbeEngines, *probeMaxEngines)
+	}
+
+	s, err := p.cfg.ModuleSettings(module, targetURL(target))
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := newEngine(p.ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	p.engines[key] = &probedEngine{engine: e, lastUsed: time.Now()}
+	return e, nil
+}
+
+func (p *prober) expire() {
+	for key, e := range p.engines {
+		if time.Since(e.lastUsed) > probeEngineTTL {
+			e.stop()
+			delete(p.engines, key)
+		}
+	}
+}
+
+// stop closes the connections to all probed engines
+func (p *prober) stop() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for key, e := range p.engines {
+		e.stop()
+		delete(p.engines, key)
+	}
+}
+
+// targetURL returns the API URL for a target given as host name or URL
+func targetURL(target string) string {
+	if strings.Contains(target, "://") {
+		return target
+	}
+
+	return "https://" + target + "/ovirt-engine/api"
+}
+
+func handleProbeRequest(w http.ResponseWriter, r *http.Request, p *prober) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+
+	module := r.URL.Query().Get("module")
+	if module == "" {
+		module = config.DefaultModule
+	}
+
+	e, err := p.engine(module, target)
+	if errors.Is(err, errTooManyProbeEngines) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = e.connect()
+	if err != nil {
+		log.Errorf("could not connect to target %s: %v", target, err)
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(e)
+
+	handleMetricsRequest(w, r, []*engine{e}, reg)
+}
@@ -0,0 +1,237 @@
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/czerwonk/ovirt_exporter/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// reloader applies changes of the configuration at runtime.
+// New engines are started for engines with changed settings, the previous ones are kept if the configuration is invalid.
+type reloader struct {
+	ctx     context.Context
+	mutex   sync.Mutex
+	cfg     *config.Config
+	engines atomic.Pointer[[]*engine]
+	probes  atomic.Pointer[prober]
+}
+
+func newReloader(ctx context.Context, cfg *config.Config) (*reloader, error) {
+	engines, err := startEngines(ctx, cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &reloader{
+		ctx: ctx,
+		cfg: cfg,
+	}
+	r.engines.Store(&engines)
+	r.probes.Store(newProber(ctx, cfg))
+
+	return r, nil
+}
+
+// startEngines starts the engines defined in the configuration.
+// Running engines with unchanged settings are kept, so their connection, metrics refreshed in background and caches are preserved.
+// Named engines which are not available are connected in background, so one engine can not prevent monitoring the others.
+func startEngines(ctx context.Context, cfg *config.Config, running []*engine) ([]*engine, error) {
+	settings, err := cfg.EngineSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	engines := make([]*engine, 0, len(settings))
+	started := make([]*engine, 0, len(settings))
+	for _, s := range settings {
+		if e := unchangedEngine(running, s); e != nil {
+			engines = append(engines, e)
+			continue
+		}
+
+		e, err := newEngine(ctx, s)
+		if err != nil {
+			stopEngines(started)
+			return nil, err
+		}
+
+		engines = append(engines, e)
+		started = append(started, e)
+
+		if backgroundRefresh() {
+			e.poller, err = e.startPoller(e.ctx)
+			if err != nil {
+				stopEngines(started)
+				return nil, err
+			}
+		}
+	}
+
+	if len(started) == 1 && started[0].settings.Name == "" {
+		err := started[0].connect()
+		if err != nil {
+			stopEngines(started)
+			return nil, err
+		}
+
+		return engines, nil
+	}
+
+	wg := &sync.WaitGroup{}
+	wg.Add(len(started))
+
+	for _, e := range started {
+		go func() {
+			defer wg.Done()
+
+			err := e.connect()
+			if err != nil {
+				log.Errorf("could not connect to engine %s (retrying in background): %v", e.settings.Name, err)
+				go e.connectInBackground()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return engines, nil
+}
+
+// unchangedEngine returns the running engine with the given settings (nil if there is none)
+func unchangedEngine(running []*engine, s config.Settings) *engine {
+	for _, e := range running {
+		if reflect.DeepEqual(e.settings, s) {
+			return e
+		}
+	}
+
+	return nil
+}
+
+func stopEngines(engines []*engine) {
+	for _, e := range engines {
+		e.stop()
+	}
+}
+
+// current returns the engines of the active configuration
+func (r *reloader) current() []*engine {
+	return *r.engines.Load()
+}
+
+// reload loads the configuration again and applies it if it is valid
+func (r *reloader) reload() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("keeping previous configuration: %w", err)
+	}
+
+	if reflect.DeepEqual(cfg, r.cfg) {
+		log.Info("Configuration unchanged")
+		return nil
+	}
+
+	if cfg.Tracing != r.cfg.Tracing {
+		log.Warn("Changes of the tracing configuration require a restart of the exporter")
+	}
+
+	engines, err := startEngines(r.ctx, cfg, r.current())
+	if err != nil {
+		return fmt.Errorf("keeping previous configuration: %w", err)
+	}
+
+	r.cfg = cfg
+	stopEngines(removedEngines(*r.engines.Swap(&engines), engines))
+	r.probes.Swap(newProber(r.ctx, cfg)).stop()
+	log.Info("Configuration reloaded")
+
+	return nil
+}
+
+// removedEngines returns the engines of previous not contained in current
+func removedEngines(previous, current []*engine) []*engine {
+	res := make([]*engine, 0, len(previous))
+	for _, p := range previous {
+		if !slices.Contains(current, p) {
+			res = append(res, p)
+		}
+	}
+
+	return res
+}
+
+// watchSignals reloads the configuration on SIGHUP until the context is cancelled
+func (r *reloader) watchSignals() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	defer signal.Stop(ch)
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ch:
+			log.Info("Received SIGHUP, reloading configuration")
+			err := r.reload()
+			if err != nil {
+				log.Errorf("could not reload configuration: %v", err)
+			}
+		}
+	}
+}
+
+func (r *reloader) handleProbeRequest(w http.ResponseWriter, req *http.Request) {
+	handleProbeRequest(w, req, r.probes.Load())
+}
+
+func (r *reloader) handleReloadRequest(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST requests allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := r.reload()
+	if err != nil {
+		log.Errorf("could not reload configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// stop stops the engines of the active configuration
+func (r *reloader) stop() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stopEngines(r.current())
+	r.probes.Load().stop()
+}
+
+// Describe implements Prometheus Collector interface.
+// No descriptors are sent since the metrics depend on the active configuration.
+func (r *reloader) Describe(ch chan<- *prometheus.Desc) {
+}
+
+// Collect implements Prometheus Collector interface
+func (r *reloader) Collect(ch chan<- prometheus.Metric) {
+	for _, e := range r.current() {
+		prometheus.WrapCollectorWith(e.labels(), e).Collect(ch)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/czerwonk/ovirt_exporter/pkg/config"
 	log "github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
@@ -25,18 +26,18 @@ var (
 	)
 )
 
-func initTracing(ctx context.Context) (func(), error) {
-	if !*tracingEnabled {
+func initTracing(ctx context.Context, cfg config.Tracing) (func(), error) {
+	if !cfg.Enabled {
 		return initTracingWithNoop()
 	}
 
-	switch *tracingProvider {
+	switch cfg.Provider {
 	case "stdout":
 		return initTracingToStdOut(ctx)
 	case "collector":
-		return initTracingToCollector(ctx)
+		return initTracingToCollector(ctx, cfg.CollectorEndpoint)
 	default:
-		log.Warnf("got invalid value for tracing.provider: %s, disable tracing", *tracingProvider)
+		log.Warnf("got invalid value for tracing.provider: %s, disable tracing", cfg.Provider)
 		return initTracingWithNoop()
 	}
 }
@@ -65,12 +66,12 @@ func initTracingToStdOut(ctx context.Context) (func(), error) {
 	return shutdownTraceProvider(ctx, tp.Shutdown), nil
 }
 
-func initTracingToCollector(ctx context.Context) (func(), error) {
-	log.Infof("Initialize tracing (agent: %s)", *tracingCollectorEndpoint)
+func initTracingToCollector(ctx context.Context, endpoint string) (func(), error) {
+	log.Infof("Initialize tracing (agent: %s)", endpoint)
 
 	cl := otlptracegrpc.NewClient(
 		otlptracegrpc.WithInsecure(),
-		otlptracegrpc.WithEndpoint(*tracingCollectorEndpoint),
+		otlptracegrpc.WithEndpoint(endpoint),
 	)
 	exp, err := otlptrace.New(ctx, cl)
 	if err != nil {